@@ -0,0 +1,134 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+	"image/gif"
+	"os"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	maxAnimationFrames = 300         // Frames after this are not played.
+	largeAnimation     = 1000 * 1000 // Pixels per frame, above which playback is slowed down.
+	minFrameDelay      = 20 * time.Millisecond
+	minLargeFrameDelay = 100 * time.Millisecond
+)
+
+// animation plays an animated GIF in the preview. Frames are composed and
+// rendered lazily, as playback reaches them, and cached for the next loop.
+type animation struct {
+	id            int
+	path          string
+	gif           *gif.GIF // Nil while loading.
+	index         int      // Current frame.
+	frames        []string // Rendered frames.
+	width, height int      // Size frames were rendered for.
+	canvas        *image.RGBA
+	previous      *image.RGBA // Canvas before the last frame, for DisposalPrevious.
+	composed      int         // Index of the last frame composed on canvas.
+}
+
+type (
+	animationLoadedMsg struct {
+		id  int
+		gif *gif.GIF
+	}
+	animationTickMsg int
+)
+
+var animationId int
+
+func loadAnimation(path string) (*animation, tea.Cmd) {
+	animationId++
+	a := &animation{id: animationId, path: path}
+	return a, func() tea.Msg {
+		file, err := os.Open(path)
+		if err != nil {
+			return animationLoadedMsg{id: a.id}
+		}
+		defer file.Close()
+		g, err := gif.DecodeAll(file)
+		if err != nil {
+			return animationLoadedMsg{id: a.id}
+		}
+		if len(g.Image) > maxAnimationFrames {
+			g.Image = g.Image[:maxAnimationFrames]
+			g.Delay = g.Delay[:maxAnimationFrames]
+			g.Disposal = g.Disposal[:maxAnimationFrames]
+		}
+		return animationLoadedMsg{id: a.id, gif: g}
+	}
+}
+
+func (a *animation) playing() bool {
+	return a.gif != nil && len(a.gif.Image) > 1
+}
+
+func (a *animation) tick() tea.Cmd {
+	// GIF delays are in hundredths of a second. Like browsers, treat too
+	// small delays as a default one.
+	delay := time.Duration(a.gif.Delay[a.index]) * 10 * time.Millisecond
+	if delay < minFrameDelay {
+		delay = 100 * time.Millisecond
+	}
+	if a.gif.Config.Width*a.gif.Config.Height > largeAnimation && delay < minLargeFrameDelay {
+		delay = minLargeFrameDelay
+	}
+	id := a.id
+	return tea.Tick(delay, func(time.Time) tea.Msg {
+		return animationTickMsg(id)
+	})
+}
+
+func (a *animation) next() {
+	a.index = (a.index + 1) % len(a.gif.Image)
+}
+
+// frame returns current frame rendered into width x height cells.
+func (a *animation) frame(width, height int) string {
+	if a.width != width || a.height != height {
+		a.width, a.height = width, height
+		a.frames = make([]string, len(a.gif.Image))
+	}
+	if a.frames[a.index] != "" {
+		return a.frames[a.index]
+	}
+	if a.canvas == nil || a.index <= a.composed {
+		bounds := image.Rect(0, 0, a.gif.Config.Width, a.gif.Config.Height)
+		a.canvas = image.NewRGBA(bounds)
+		a.composed = -1
+	}
+	for a.composed < a.index {
+		a.compose(a.composed + 1)
+	}
+	a.frames[a.index] = renderImage(a.canvas, width, height)
+	return a.frames[a.index]
+}
+
+// compose draws frame i on canvas, first disposing of the previous frame as
+// it requested.
+func (a *animation) compose(i int) {
+	if i > 0 {
+		prev := a.gif.Image[i-1]
+		switch a.gif.Disposal[i-1] {
+		case gif.DisposalBackground:
+			draw.Draw(a.canvas, prev.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			if a.previous != nil {
+				draw.Draw(a.canvas, a.canvas.Bounds(), a.previous, image.Point{}, draw.Src)
+			}
+		}
+	}
+	if a.gif.Disposal[i] == gif.DisposalPrevious {
+		if a.previous == nil {
+			a.previous = image.NewRGBA(a.canvas.Bounds())
+		}
+		draw.Draw(a.previous, a.previous.Bounds(), a.canvas, image.Point{}, draw.Src)
+	}
+	frame := a.gif.Image[i]
+	draw.Draw(a.canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+	a.composed = i
+}
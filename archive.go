@@ -0,0 +1,278 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	maxArchiveEntries  = 10000       // Entries after this are counted, but not listed.
+	archiveScanTimeout = time.Second // Compressed tars are read at most this long.
+)
+
+var (
+	errNotArchive     = errors.New("not an archive")
+	errArchiveReading = errors.New("reading archive")
+)
+
+type archiveEntry struct {
+	name       string
+	size       int64
+	compressed int64 // Zero if format does not tell.
+	modTime    time.Time
+	dir        bool
+}
+
+type archiveListing struct {
+	format      string
+	entries     []archiveEntry
+	files, dirs int
+	size        int64
+	compressed  int64 // Size of the packed data.
+	truncated   bool  // Reading stopped early, totals are incomplete.
+}
+
+// archiveFormat detects archive by its content: "zip", "tar", "tar.gz",
+// "tar.bz2", or empty string. Compressed files are tars only if a tar header
+// follows.
+func archiveFormat(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return "zip"
+	case isTar(head):
+		return "tar"
+	case bytes.HasPrefix(head, []byte("\x1f\x8b")):
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return ""
+		}
+		r, err := gzip.NewReader(file)
+		if err != nil {
+			return ""
+		}
+		if n, _ := io.ReadFull(r, head[:cap(head)]); isTar(head[:n]) {
+			return "tar.gz"
+		}
+	case bytes.HasPrefix(head, []byte("BZh")):
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return ""
+		}
+		if n, _ := io.ReadFull(bzip2.NewReader(file), head[:cap(head)]); isTar(head[:n]) {
+			return "tar.bz2"
+		}
+	}
+	return ""
+}
+
+func isTar(head []byte) bool {
+	return len(head) >= 262 && string(head[257:262]) == "ustar"
+}
+
+type archiveCacheEntry struct {
+	path    string
+	modTime int64
+	format  string // Empty if file is not an archive.
+	reading bool   // Listing is being read in background.
+	listed  bool
+	content string
+	info    string
+	err     error
+}
+
+type archiveMsg archiveCacheEntry
+
+// Last archive seen by preview. Its format is detected from the head of the
+// file, but a compressed tar has to be decompressed to be listed, which may
+// take up to archiveScanTimeout, so the listing is read in background.
+var archiveCache archiveCacheEntry
+
+// previewArchive returns table of contents of an archive and a summary for
+// the preview header, errArchiveReading until the listing is read, or
+// errNotArchive.
+func previewArchive(path string) (string, string, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return "", "", err
+	}
+	if archiveCache.path != path || archiveCache.modTime != stat.ModTime().UnixNano() {
+		archiveCache = archiveCacheEntry{
+			path:    path,
+			modTime: stat.ModTime().UnixNano(),
+			format:  archiveFormat(path),
+		}
+	}
+	switch {
+	case archiveCache.format == "":
+		return "", "", errNotArchive
+	case !archiveCache.listed:
+		return "", "", errArchiveReading
+	}
+	return archiveCache.content, archiveCache.info, archiveCache.err
+}
+
+// runListArchive reads listing of the archive in background, unless it is
+// read already or being read.
+func runListArchive(path string) tea.Cmd {
+	if _, _, err := previewArchive(path); err != errArchiveReading || archiveCache.reading {
+		return nil
+	}
+	archiveCache.reading = true
+	entry := archiveCache
+	return func() tea.Msg {
+		entry.reading, entry.listed = false, true
+		listing, err := listArchive(entry.path, entry.format)
+		if err != nil {
+			entry.err = err
+			return archiveMsg(entry)
+		}
+		entry.content = listing.render()
+		entry.info = fmt.Sprintf("%s %s %s", strings.ToUpper(entry.format), plural(listing.files, "file"), humanSize(listing.size))
+		return archiveMsg(entry)
+	}
+}
+
+func listArchive(path, format string) (*archiveListing, error) {
+	listing := &archiveListing{format: format}
+
+	if format == "zip" {
+		// Zip has the central directory at the end, nothing else is read.
+		r, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		for _, f := range r.File {
+			listing.add(archiveEntry{
+				name:       f.Name,
+				size:       int64(f.UncompressedSize64),
+				compressed: int64(f.CompressedSize64),
+				modTime:    f.Modified,
+				dir:        f.FileInfo().IsDir(),
+			})
+		}
+		return listing, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if stat, err := file.Stat(); err == nil {
+		listing.compressed = stat.Size()
+	}
+
+	// Plain tar is read header by header, seeking over the content. Compressed
+	// one has to be decompressed whole, so give up on huge ones.
+	var r io.Reader = file
+	switch format {
+	case "tar.gz":
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		r = gz
+	case "tar.bz2":
+		r = bzip2.NewReader(file)
+	}
+	tr := tar.NewReader(r)
+	start := time.Now()
+	for {
+		if format != "tar" && time.Since(start) > archiveScanTimeout {
+			listing.truncated = true
+			break
+		}
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if len(listing.entries) > 0 {
+				// Show what was read of a damaged archive.
+				listing.truncated = true
+				break
+			}
+			return nil, err
+		}
+		name := h.Name
+		if h.Typeflag == tar.TypeSymlink || h.Typeflag == tar.TypeLink {
+			name += " -> " + h.Linkname
+		}
+		listing.add(archiveEntry{
+			name:    name,
+			size:    h.Size,
+			modTime: h.ModTime,
+			dir:     h.Typeflag == tar.TypeDir,
+		})
+	}
+	return listing, nil
+}
+
+func (l *archiveListing) add(e archiveEntry) {
+	if e.dir {
+		l.dirs++
+	} else {
+		l.files++
+		l.size += e.size
+		l.compressed += e.compressed
+	}
+	if len(l.entries) < maxArchiveEntries {
+		l.entries = append(l.entries, e)
+	}
+}
+
+func (l *archiveListing) render() string {
+	var b strings.Builder
+	packed := l.format == "zip"
+	if packed {
+		b.WriteString(bold.Render(fmt.Sprintf("%8s  %8s  %-16s  %s", "Size", "Packed", "Modified", "Name")) + "\n")
+	} else {
+		b.WriteString(bold.Render(fmt.Sprintf("%8s  %-16s  %s", "Size", "Modified", "Name")) + "\n")
+	}
+	for _, e := range l.entries {
+		size, compressed := humanSize(e.size), humanSize(e.compressed)
+		if e.dir {
+			size, compressed = "-", "-"
+		}
+		modTime := e.modTime.Local().Format("2006-01-02 15:04")
+		if packed {
+			_, _ = fmt.Fprintf(&b, "%8s  %8s  %-16s  %s\n", size, compressed, modTime, e.name)
+		} else {
+			_, _ = fmt.Fprintf(&b, "%8s  %-16s  %s\n", size, modTime, e.name)
+		}
+	}
+
+	if hidden := l.files + l.dirs - len(l.entries); hidden > 0 {
+		_, _ = fmt.Fprintf(&b, "… and %d more\n", hidden)
+	}
+	b.WriteString("\n")
+	total := fmt.Sprintf("%s, %s, %s", plural(l.files, "file"), plural(l.dirs, "dir"), humanSize(l.size))
+	if l.size > 0 && l.compressed > 0 {
+		total += fmt.Sprintf(" (%s packed, %.0f%%)", humanSize(l.compressed), float64(l.compressed)*100/float64(l.size))
+	}
+	if l.truncated {
+		total += ", listing incomplete"
+	}
+	b.WriteString(bold.Render(total))
+	return b.String()
+}
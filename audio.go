@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dhowden/tag"
+)
+
+type audioInfo struct {
+	format   string
+	title    string
+	artist   string
+	album    string
+	track    string
+	year     string
+	genre    string
+	duration time.Duration
+	bitrate  int         // In kbps.
+	cover    image.Image // Embedded cover art.
+
+	// Rendered preview, for the size it was rendered for.
+	width, height int
+	content       string
+}
+
+type audioEntry struct {
+	path    string
+	modTime int64
+	info    *audioInfo
+}
+
+// Tags of the last audio file. Reading them decodes the cover art, and MP3
+// duration needs frame headers to be found.
+var audioCache audioEntry
+
+func isAudio(path string) bool {
+	switch extension(path) {
+	case "mp3", "flac", "ogg", "oga", "opus", "m4a", "m4b":
+		return true
+	}
+	return false
+}
+
+// readAudio returns tags and stream properties of an audio file, or nil if
+// it is not one.
+func readAudio(path string) *audioInfo {
+	if !isAudio(path) {
+		return nil
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	if audioCache.path == path && audioCache.modTime == stat.ModTime().UnixNano() {
+		return audioCache.info
+	}
+	audioCache = audioEntry{path: path, modTime: stat.ModTime().UnixNano()}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	info := &audioInfo{format: strings.ToUpper(extension(path))}
+	if m, err := tag.ReadFrom(file); err == nil {
+		info.title = strings.TrimSpace(m.Title())
+		info.artist = strings.TrimSpace(m.Artist())
+		info.album = strings.TrimSpace(m.Album())
+		info.genre = strings.TrimSpace(m.Genre())
+		if n, total := m.Track(); n > 0 && total > 0 {
+			info.track = fmt.Sprintf("%d/%d", n, total)
+		} else if n > 0 {
+			info.track = fmt.Sprint(n)
+		}
+		if m.Year() > 0 {
+			info.year = fmt.Sprint(m.Year())
+		}
+		if p := m.Picture(); p != nil {
+			info.cover, _, _ = image.Decode(bytes.NewReader(p.Data))
+		}
+	}
+
+	// Tags say nothing about the stream, so read it from headers.
+	var duration time.Duration
+	switch extension(path) {
+	case "mp3":
+		duration, info.bitrate = mp3Duration(file, stat.Size())
+	case "flac":
+		duration = flacDuration(file)
+	case "ogg", "oga", "opus":
+		duration = oggDuration(file, stat.Size())
+	case "m4a", "m4b":
+		duration = mp4Duration(file, stat.Size())
+	}
+	info.duration = duration
+	if info.bitrate == 0 && duration > 0 {
+		info.bitrate = int(float64(stat.Size()) * 8 / duration.Seconds() / 1000)
+	}
+
+	audioCache.info = info
+	return info
+}
+
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	s := int(d.Round(time.Second).Seconds())
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+func (a *audioInfo) bitrateString() string {
+	if a.bitrate <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d kbps", a.bitrate)
+}
+
+// summary is shown in the preview header, like "MP3 3:45 320 kbps".
+func (a *audioInfo) summary() string {
+	return strings.Join(strings.Fields(a.format+" "+formatDuration(a.duration)+" "+a.bitrateString()), " ")
+}
+
+func (a *audioInfo) lines() []string {
+	var lines []string
+	add := func(name, value string) {
+		if value != "" {
+			lines = append(lines, bold.Render(fmt.Sprintf("%-12s", name))+value)
+		}
+	}
+	add("Title", a.title)
+	add("Artist", a.artist)
+	add("Album", a.album)
+	add("Track", a.track)
+	add("Year", a.year)
+	add("Genre", a.genre)
+	add("Duration", formatDuration(a.duration))
+	add("Bitrate", a.bitrateString())
+	return lines
+}
+
+// preview renders tags with the cover art above them, fitted into width
+// x height cells.
+func (a *audioInfo) preview(width, height int) string {
+	if a.width == width && a.height == height && a.content != "" {
+		return a.content
+	}
+	a.width, a.height = width, height
+
+	lines := a.lines()
+	if len(lines) == 0 {
+		lines = []string{"No tags"}
+	}
+	a.content = strings.Join(lines, "\n")
+	if a.cover != nil {
+		coverHeight := max(1, height-len(lines)-1) // Subtract 1 for empty line.
+		cover := strings.TrimSuffix(renderImage(a.cover, width, coverHeight), "\n")
+		a.content = cover + "\n\n" + a.content
+	}
+	return a.content
+}
+
+// mp3Duration reads the first MPEG audio frame. VBR files have a Xing or
+// VBRI header there with a number of frames, for CBR files duration follows
+// from the bitrate. Returns also bitrate in kbps.
+func mp3Duration(r io.ReaderAt, size int64) (time.Duration, int) {
+	var offset int64
+	header := make([]byte, 10)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return 0, 0
+	}
+	if string(header[0:3]) == "ID3" {
+		// Size of ID3v2 tag is stored in 7 bits per byte.
+		offset = int64(header[6])<<21 | int64(header[7])<<14 | int64(header[8])<<7 | int64(header[9])
+		offset += 10
+		if header[5]&0x10 != 0 {
+			offset += 10 // Footer.
+		}
+	}
+
+	buf := make([]byte, 64*1024)
+	n, _ := r.ReadAt(buf, offset)
+	buf = buf[:n]
+
+	for i := 0; i+4 <= len(buf); i++ {
+		if buf[i] != 0xff || buf[i+1]&0xe0 != 0xe0 {
+			continue
+		}
+		h := buf[i : i+4]
+		version := (h[1] >> 3) & 3 // 3 is MPEG-1, 2 is MPEG-2, 0 is MPEG-2.5.
+		layer := (h[1] >> 1) & 3   // 1 is Layer III.
+		bitrateIndex := h[2] >> 4
+		rateIndex := (h[2] >> 2) & 3
+		if version == 1 || layer != 1 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+			continue
+		}
+		mono := h[3]>>6 == 3
+
+		sampleRate := []int{44100, 48000, 32000}[rateIndex]
+		bitrate := []int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320}[bitrateIndex]
+		samplesPerFrame := 1152
+		sideInfo := 32
+		if mono {
+			sideInfo = 17
+		}
+		if version != 3 {
+			sampleRate /= 2
+			if version == 0 {
+				sampleRate /= 2
+			}
+			bitrate = []int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160}[bitrateIndex]
+			samplesPerFrame = 576
+			sideInfo = 17
+			if mono {
+				sideInfo = 9
+			}
+		}
+		audioSize := size - offset - int64(i)
+
+		var frames int64
+		if x := i + 4 + sideInfo; x+12 <= len(buf) && (string(buf[x:x+4]) == "Xing" || string(buf[x:x+4]) == "Info") {
+			if binary.BigEndian.Uint32(buf[x+4:])&1 != 0 {
+				frames = int64(binary.BigEndian.Uint32(buf[x+8:]))
+			}
+		} else if v := i + 4 + 32; v+18 <= len(buf) && string(buf[v:v+4]) == "VBRI" {
+			frames = int64(binary.BigEndian.Uint32(buf[v+14:]))
+		}
+		if frames > 0 {
+			seconds := float64(frames) * float64(samplesPerFrame) / float64(sampleRate)
+			return time.Duration(seconds * float64(time.Second)), int(float64(audioSize) * 8 / seconds / 1000)
+		}
+		seconds := float64(audioSize) * 8 / float64(bitrate*1000)
+		return time.Duration(seconds * float64(time.Second)), bitrate
+	}
+	return 0, 0
+}
+
+// flacDuration reads total samples and sample rate from STREAMINFO, the
+// first metadata block.
+func flacDuration(r io.ReaderAt) time.Duration {
+	b := make([]byte, 26)
+	if _, err := r.ReadAt(b, 0); err != nil || string(b[0:4]) != "fLaC" {
+		return 0
+	}
+	// Sample rate is 20 bits, then 3 bits of channels, 5 bits of sample
+	// size and 36 bits of total samples.
+	v := binary.BigEndian.Uint64(b[18:26])
+	sampleRate := v >> 44
+	samples := v & (1<<36 - 1)
+	if sampleRate == 0 {
+		return 0
+	}
+	return time.Duration(float64(samples) / float64(sampleRate) * float64(time.Second))
+}
+
+// oggDuration divides granule position of the last page by the sample rate
+// from the identification header of Vorbis or Opus stream.
+func oggDuration(r io.ReaderAt, size int64) time.Duration {
+	head := make([]byte, 512)
+	n, _ := r.ReadAt(head, 0)
+	head = head[:n]
+	// The page header is followed by a segment table of head[26] bytes.
+	if len(head) < 27 || string(head[0:4]) != "OggS" || len(head) < 27+int(head[26]) {
+		return 0
+	}
+	packet := head[27+int(head[26]):]
+
+	var sampleRate, preSkip int64
+	switch {
+	case len(packet) >= 16 && string(packet[0:7]) == "\x01vorbis":
+		sampleRate = int64(binary.LittleEndian.Uint32(packet[12:16]))
+	case len(packet) >= 12 && string(packet[0:8]) == "OpusHead":
+		// Opus granule is always in 48 kHz samples.
+		sampleRate = 48000
+		preSkip = int64(binary.LittleEndian.Uint16(packet[10:12]))
+	default:
+		return 0
+	}
+
+	tailSize := min(size, 64*1024)
+	tail := make([]byte, tailSize)
+	if _, err := r.ReadAt(tail, size-tailSize); err != nil && err != io.EOF {
+		return 0
+	}
+	last := bytes.LastIndex(tail, []byte("OggS"))
+	if last < 0 || last+14 > len(tail) || sampleRate == 0 {
+		return 0
+	}
+	granule := int64(binary.LittleEndian.Uint64(tail[last+6:])) - preSkip
+	return time.Duration(float64(granule) / float64(sampleRate) * float64(time.Second))
+}
+
+// mp4Duration finds movie header atom "moov/mvhd" with time scale and
+// duration.
+func mp4Duration(r io.ReaderAt, size int64) time.Duration {
+	atom := func(offset, end int64, name string) (int64, int64, bool) {
+		b := make([]byte, 16)
+		for offset+8 <= end {
+			if _, err := r.ReadAt(b[:8], offset); err != nil {
+				return 0, 0, false
+			}
+			atomSize := int64(binary.BigEndian.Uint32(b[0:4]))
+			header := int64(8)
+			if atomSize == 1 {
+				if _, err := r.ReadAt(b[8:16], offset+8); err != nil {
+					return 0, 0, false
+				}
+				atomSize = int64(binary.BigEndian.Uint64(b[8:16]))
+				header = 16
+			} else if atomSize == 0 {
+				atomSize = end - offset
+			}
+			if atomSize < header {
+				return 0, 0, false
+			}
+			if string(b[4:8]) == name {
+				return offset + header, offset + atomSize, true
+			}
+			offset += atomSize
+		}
+		return 0, 0, false
+	}
+
+	start, end, ok := atom(0, size, "moov")
+	if !ok {
+		return 0
+	}
+	start, end, ok = atom(start, end, "mvhd")
+	if !ok || end-start < 32 {
+		return 0
+	}
+	b := make([]byte, 32)
+	if _, err := r.ReadAt(b, start); err != nil {
+		return 0
+	}
+	var timescale, duration uint64
+	if b[0] == 1 {
+		timescale = uint64(binary.BigEndian.Uint32(b[20:24]))
+		duration = binary.BigEndian.Uint64(b[24:32])
+	} else {
+		timescale = uint64(binary.BigEndian.Uint32(b[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(b[16:20]))
+	}
+	if timescale == 0 {
+		return 0
+	}
+	return time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestOggDuration(t *testing.T) {
+	page := func(granule uint64, packet []byte) []byte {
+		b := []byte("OggS\x00\x00")
+		b = binary.LittleEndian.AppendUint64(b, granule)
+		b = append(b, make([]byte, 12)...) // Serial, sequence and checksum.
+		b = append(b, 1, byte(len(packet)))
+		return append(b, packet...)
+	}
+	opus := []byte("OpusHead\x01\x02")
+	opus = binary.LittleEndian.AppendUint16(opus, 312) // Pre-skip.
+	opus = append(opus, make([]byte, 7)...)
+	file := append(page(0, opus), page(48000*3+312, nil)...)
+
+	truncated := page(0, opus)[:30]
+	truncated[26] = 255 // Segment table is longer than the file.
+
+	testCases := []struct {
+		name     string
+		data     []byte
+		expected time.Duration
+	}{
+		{"opus", file, 3 * time.Second},
+		{"empty", nil, 0},
+		{"truncated header", truncated, 0},
+	}
+	for _, tc := range testCases {
+		result := oggDuration(bytes.NewReader(tc.data), int64(len(tc.data)))
+		if result != tc.expected {
+			t.Errorf("Failed: %s: %v != %v", tc.name, result, tc.expected)
+		}
+	}
+}
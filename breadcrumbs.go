@@ -0,0 +1,176 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// crumb is a directory in the location bar.
+type crumb struct {
+	name string
+	path string
+}
+
+// crumbSpan is a crumb, or collapsed crumbs, drawn at column x.
+type crumbSpan struct {
+	x, width int
+	path     string
+}
+
+// crumbs splits the current path into directories, starting from the root
+// or from the home directory, which is shown as ~.
+func (m *model) crumbs() []crumb {
+	home, _ := os.UserHomeDir()
+	var crumbs []crumb
+	for p := filepath.Clean(m.path); ; p = filepath.Dir(p) {
+		if home != "" && p == home {
+			crumbs = append(crumbs, crumb{"~", p})
+			break
+		}
+		if filepath.Dir(p) == p {
+			crumbs = append(crumbs, crumb{p, p}) // Root, like / or C:\.
+			break
+		}
+		crumbs = append(crumbs, crumb{filepath.Base(p), p})
+	}
+	for i, j := 0, len(crumbs)-1; i < j; i, j = i+1, j-1 {
+		crumbs[i], crumbs[j] = crumbs[j], crumbs[i]
+	}
+	return crumbs
+}
+
+// breadcrumbs renders the location bar within width. If it is too long,
+// directories in the middle are collapsed into an ellipsis, while the first
+// one, the current one and the focused one are always shown.
+func (m *model) breadcrumbs(width int) (string, []crumbSpan) {
+	crumbs := m.crumbs()
+	focus := len(crumbs) - 1
+	if m.breadcrumbsFocus {
+		m.crumb = max(0, min(m.crumb, len(crumbs)-1))
+		focus = m.crumb
+	}
+
+	show := make([]bool, len(crumbs))
+	show[0], show[focus], show[len(crumbs)-1] = true, true, true
+	if _, spans := layoutCrumbs(crumbs, show); spanWidth(spans) > width && focus > 0 {
+		show[0] = false
+	}
+	// Show more directories around the focused one, while they fit.
+	for d := 1; d < len(crumbs); d++ {
+		for _, i := range []int{focus - d, focus + d} {
+			if i < 0 || i >= len(crumbs) || show[i] {
+				continue
+			}
+			show[i] = true
+			if _, spans := layoutCrumbs(crumbs, show); spanWidth(spans) > width {
+				show[i] = false
+			}
+		}
+	}
+
+	names, spans := layoutCrumbs(crumbs, show)
+	if over := spanWidth(spans) - width; over > 0 {
+		// Even the current directory does not fit, cut its beginning.
+		last := len(names) - 1
+		names[last] = truncateLeft(names[last], max(1, strlen(names[last])-over))
+		spans[last].width = strlen(names[last])
+	}
+
+	var b strings.Builder
+	for i, name := range names {
+		if i > 0 && !strings.HasSuffix(names[i-1], fileSeparator) {
+			b.WriteString(bar.Render(fileSeparator))
+		}
+		if m.breadcrumbsFocus && spans[i].path == crumbs[focus].path {
+			b.WriteString(cursor.Render(name))
+		} else {
+			b.WriteString(bar.Render(name))
+		}
+	}
+	return b.String(), spans
+}
+
+// layoutCrumbs returns names of shown crumbs and their positions. Hidden
+// crumbs in a row are replaced with an ellipsis leading to the last of them.
+func layoutCrumbs(crumbs []crumb, show []bool) ([]string, []crumbSpan) {
+	var names []string
+	var spans []crumbSpan
+	x := 0
+	for i, c := range crumbs {
+		if !show[i] && i+1 < len(crumbs) && !show[i+1] {
+			continue // Collapsed into the next one.
+		}
+		name := c.name
+		if !show[i] {
+			name = "…"
+		}
+		if len(names) > 0 && !strings.HasSuffix(names[len(names)-1], fileSeparator) {
+			x += len(fileSeparator)
+		}
+		names = append(names, name)
+		spans = append(spans, crumbSpan{x: x, width: strlen(name), path: c.path})
+		x += strlen(name)
+	}
+	return names, spans
+}
+
+func spanWidth(spans []crumbSpan) int {
+	if len(spans) == 0 {
+		return 0
+	}
+	last := spans[len(spans)-1]
+	return last.x + last.width
+}
+
+func (m *model) updateBreadcrumbs(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	crumbs := m.crumbs()
+	switch {
+	case key.Matches(msg, keyForceQuit):
+		m.quitting = true
+		m.exitCode = 2
+		m.dontDoPendingDeletions()
+		return m, tea.Quit
+
+	case key.Matches(msg, keyQuit, keyQuitQ, keyBreadcrumbs):
+		m.breadcrumbsFocus = false
+
+	case key.Matches(msg, keyLeft, keyVimLeft, keyBack):
+		m.crumb = max(0, m.crumb-1)
+
+	case key.Matches(msg, keyRight, keyVimRight):
+		m.crumb = min(len(crumbs)-1, m.crumb+1)
+
+	case key.Matches(msg, keyHome, keyLeftmost, keyVimTop):
+		m.crumb = 0
+
+	case key.Matches(msg, keyEnd, keyRightmost, keyVimBottom):
+		m.crumb = len(crumbs) - 1
+
+	case key.Matches(msg, keyOpen):
+		m.breadcrumbsFocus = false
+		if m.crumb < len(crumbs) {
+			m.goToAncestor(crumbs[m.crumb].path)
+		}
+	}
+	return m, nil
+}
+
+// goToAncestor changes the current directory to dir, which contains it,
+// with the cursor on the directory we came from.
+func (m *model) goToAncestor(dir string) {
+	rel, err := filepath.Rel(dir, m.path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return
+	}
+	m.search = ""
+	m.searchMode = false
+	m.path = dir
+	m.prevName = strings.Split(rel, string(filepath.Separator))[0]
+	m.findPrevName = true
+	m.c, m.r, m.offset = 0, 0, 0
+	m.list()
+}
@@ -0,0 +1,469 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/antonmedv/clipboard"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"golang.org/x/crypto/blake2b"
+)
+
+// Names of checksum algorithms, in order of switching between them.
+var checksumAlgorithms = []string{"SHA-256", "SHA-1", "MD5", "BLAKE2b"}
+
+func newHash(algorithm string) hash.Hash {
+	switch algorithm {
+	case "SHA-1":
+		return sha1.New()
+	case "MD5":
+		return md5.New()
+	case "BLAKE2b":
+		h, _ := blake2b.New512(nil) // Same as b2sum.
+		return h
+	}
+	return sha256.New()
+}
+
+// sumFile computes checksum of the file, stopping when ctx is cancelled.
+func sumFile(ctx context.Context, path, algorithm string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := newHash(algorithm)
+	buf := make([]byte, 64*1024)
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		n, err := file.Read(buf)
+		h.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sumsEntry is a line of a checksums file.
+type sumsEntry struct {
+	name      string
+	sum       string
+	algorithm string
+}
+
+var bsdSumLine = regexp.MustCompile(`^(\w+) ?\((.+)\) ?= ?([0-9a-fA-F]+)$`)
+
+// parseSums reads a checksums file in the format of sha256sum and friends,
+// "<hex>  <name>" or "<hex> *<name>", or in BSD format "SHA256 (<name>) = <hex>".
+// Algorithm is guessed from the file name or the length of checksums.
+func parseSums(r io.Reader, fileName string) ([]sumsEntry, error) {
+	var entries []sumsEntry
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var e sumsEntry
+		if m := bsdSumLine.FindStringSubmatch(line); m != nil {
+			e = sumsEntry{name: m[2], sum: m[3], algorithm: algorithmByName(m[1])}
+		} else {
+			sum, name, ok := strings.Cut(line, " ")
+			if !ok {
+				return nil, fmt.Errorf("invalid line: %s", line)
+			}
+			name = strings.TrimPrefix(strings.TrimPrefix(name, " "), "*")
+			e = sumsEntry{name: name, sum: sum}
+		}
+		if _, err := hex.DecodeString(e.sum); err != nil {
+			return nil, fmt.Errorf("invalid checksum: %s", e.sum)
+		}
+		e.sum = strings.ToLower(e.sum)
+		if e.algorithm == "" {
+			e.algorithm = algorithmByName(fileName)
+		}
+		if e.algorithm == "" {
+			e.algorithm = algorithmByLength(len(e.sum))
+		}
+		if e.algorithm == "" {
+			return nil, fmt.Errorf("unknown checksum: %s", e.sum)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+func algorithmByName(name string) string {
+	name = strings.ToUpper(name)
+	switch {
+	case strings.Contains(name, "SHA256"), strings.Contains(name, "SHA-256"):
+		return "SHA-256"
+	case strings.Contains(name, "SHA1"), strings.Contains(name, "SHA-1"):
+		return "SHA-1"
+	case strings.Contains(name, "MD5"):
+		return "MD5"
+	case strings.Contains(name, "B2"), strings.Contains(name, "BLAKE2"):
+		return "BLAKE2b"
+	}
+	return ""
+}
+
+func algorithmByLength(n int) string {
+	switch n {
+	case 64:
+		return "SHA-256"
+	case 40:
+		return "SHA-1"
+	case 32:
+		return "MD5"
+	case 128:
+		return "BLAKE2b"
+	}
+	return ""
+}
+
+// findSumsFile returns the checksums file to verify: the selected file if it
+// looks like one, or a well-known one in dir.
+func findSumsFile(dir, selected string) string {
+	name := strings.ToUpper(filepath.Base(selected))
+	if strings.HasSuffix(name, "SUMS") || strings.HasSuffix(name, "SUMS.TXT") ||
+		strings.HasSuffix(name, ".SHA256") || strings.HasSuffix(name, ".SHA1") ||
+		strings.HasSuffix(name, ".MD5") || strings.Contains(name, "CHECKSUM") {
+		return selected
+	}
+	for _, name := range []string{"SHA256SUMS", "sha256sums.txt", "SHA1SUMS", "MD5SUMS", "B2SUMS", "CHECKSUMS"} {
+		path := filepath.Join(dir, name)
+		if fi, err := os.Stat(path); err == nil && fi.Mode().IsRegular() {
+			return path
+		}
+	}
+	return ""
+}
+
+// checksums is an overlay with checksums of files, or with results of
+// verification of a checksums file.
+type checksums struct {
+	id        int
+	dir       string
+	sumsFile  string // Checksums file being verified, empty for computing.
+	files     []string
+	expected  []sumsEntry         // For verification.
+	sums      []map[string]string // Per file and algorithm, as computed so far.
+	errs      []error
+	algorithm int                // Index in checksumAlgorithms.
+	cancel    context.CancelFunc // Stops computing the current checksum.
+	message   string
+}
+
+type checksumMsg struct {
+	id        int
+	index     int
+	algorithm string
+	sum       string
+	err       error
+}
+
+var checksumsId int
+
+// openChecksums computes checksums of selected files, or of the current file.
+func (m *model) openChecksums() tea.Cmd {
+	var files []string
+	for path := range m.selected {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+	if len(files) == 0 {
+		if filePath, ok := m.filePath(); ok {
+			files = append(files, filePath)
+		}
+	}
+	var regular []string
+	for _, file := range files {
+		if fi, err := os.Stat(file); err == nil && fi.Mode().IsRegular() {
+			regular = append(regular, file)
+		}
+	}
+	m.checksums = &checksums{
+		dir:   m.path,
+		files: regular,
+		sums:  make([]map[string]string, len(regular)),
+		errs:  make([]error, len(regular)),
+	}
+	if len(regular) == 0 {
+		m.checksums.message = "No files to compute checksums of"
+		return nil
+	}
+	return m.checksums.next()
+}
+
+// openVerify checks files listed in a checksums file in the current directory.
+func (m *model) openVerify() tea.Cmd {
+	filePath, _ := m.filePath()
+	c := &checksums{dir: m.path}
+	m.checksums = c
+	c.sumsFile = findSumsFile(m.path, filePath)
+	if c.sumsFile == "" {
+		c.message = "No checksums file found, like SHA256SUMS"
+		return nil
+	}
+	file, err := os.Open(c.sumsFile)
+	if err != nil {
+		c.message = err.Error()
+		return nil
+	}
+	defer file.Close()
+	c.expected, err = parseSums(file, filepath.Base(c.sumsFile))
+	if err != nil {
+		c.message = err.Error()
+		return nil
+	}
+	for _, e := range c.expected {
+		c.files = append(c.files, filepath.Join(filepath.Dir(c.sumsFile), filepath.FromSlash(e.name)))
+	}
+	if len(c.files) == 0 {
+		c.message = "No checksums in " + filepath.Base(c.sumsFile)
+		return nil
+	}
+	c.sums = make([]map[string]string, len(c.files))
+	c.errs = make([]error, len(c.files))
+	return c.next()
+}
+
+// algorithmOf returns the algorithm to compute for the file at index: the
+// shown one, or the one of the checksums file.
+func (c *checksums) algorithmOf(index int) string {
+	if c.sumsFile != "" {
+		return c.expected[index].algorithm
+	}
+	return checksumAlgorithms[c.algorithm]
+}
+
+// done reports whether checksum of the file at index is known or failed.
+func (c *checksums) done(index int) bool {
+	_, ok := c.sums[index][c.algorithmOf(index)]
+	return ok || c.errs[index] != nil
+}
+
+// next computes in background the first checksum not known yet, stopping
+// the one being computed. Files are processed one after another, so results
+// appear as they are ready.
+func (c *checksums) next() tea.Cmd {
+	c.stop()
+	for index := range c.files {
+		if c.done(index) {
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		checksumsId++
+		c.id, c.cancel = checksumsId, cancel
+		id, path, algorithm := c.id, c.files[index], c.algorithmOf(index)
+		return func() tea.Msg {
+			sum, err := sumFile(ctx, path, algorithm)
+			return checksumMsg{id: id, index: index, algorithm: algorithm, sum: sum, err: err}
+		}
+	}
+	return nil
+}
+
+func (c *checksums) stop() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.cancel = nil
+}
+
+func (m *model) checksumDone(msg checksumMsg) tea.Cmd {
+	c := m.checksums
+	if c == nil || c.id != msg.id {
+		return nil
+	}
+	if msg.err != nil {
+		c.errs[msg.index] = msg.err
+	} else {
+		if c.sums[msg.index] == nil {
+			c.sums[msg.index] = make(map[string]string)
+		}
+		c.sums[msg.index][msg.algorithm] = msg.sum
+	}
+	return c.next()
+}
+
+func (m *model) updateChecksums(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	c := m.checksums
+	c.message = ""
+	switch {
+	case key.Matches(msg, keyForceQuit):
+		c.stop()
+		m.quitting = true
+		m.exitCode = 2
+		m.dontDoPendingDeletions()
+		return m, tea.Quit
+
+	case key.Matches(msg, keyQuit, keyQuitQ, keyChecksum, keyVerify):
+		c.stop()
+		m.checksums = nil
+
+	case key.Matches(msg, keyChecksumNext, keyRight, keyVimRight):
+		if c.sumsFile == "" {
+			c.algorithm = (c.algorithm + 1) % len(checksumAlgorithms)
+			return m, c.next()
+		}
+
+	case key.Matches(msg, keyLeft, keyVimLeft):
+		if c.sumsFile == "" {
+			c.algorithm = (c.algorithm + len(checksumAlgorithms) - 1) % len(checksumAlgorithms)
+			return m, c.next()
+		}
+
+	case key.Matches(msg, keyYank):
+		if c.sumsFile != "" {
+			break
+		}
+		algorithm := checksumAlgorithms[c.algorithm]
+		var lines []string
+		for i := range c.files {
+			if sum, ok := c.sums[i][algorithm]; ok {
+				lines = append(lines, sum+"  "+c.name(i))
+			}
+		}
+		if len(lines) == 0 {
+			break
+		}
+		text := strings.Join(lines, "\n")
+		if len(c.files) == 1 {
+			text = c.sums[0][algorithm] // Only the checksum for a single file.
+		}
+		if err := clipboard.WriteAll(text); err != nil {
+			c.message = err.Error()
+		} else {
+			c.message = "Copied " + algorithm
+		}
+	}
+	return m, nil
+}
+
+// computed returns number of files with known or failed checksums.
+func (c *checksums) computed() int {
+	n := 0
+	for i := range c.files {
+		if c.done(i) {
+			n++
+		}
+	}
+	return n
+}
+
+// name returns file name relative to the directory of checksums.
+func (c *checksums) name(i int) string {
+	dir := c.dir
+	if c.sumsFile != "" {
+		dir = filepath.Dir(c.sumsFile)
+	}
+	if rel, err := filepath.Rel(dir, c.files[i]); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return c.files[i]
+}
+
+func (m *model) checksumsView() string {
+	c := m.checksums
+	width := m.termWidth - 4 // Border and padding.
+	var title string
+	var lines []string
+
+	if c.sumsFile == "" {
+		algorithm := checksumAlgorithms[c.algorithm]
+		var tabs []string
+		for i, a := range checksumAlgorithms {
+			if i == c.algorithm {
+				tabs = append(tabs, cursor.Render(" "+a+" "))
+			} else {
+				tabs = append(tabs, " "+a+" ")
+			}
+		}
+		title = strings.Join(tabs, "")
+		for i := range c.files {
+			var line string
+			switch {
+			case c.errs[i] != nil:
+				line = danger.Render(c.errs[i].Error())
+			case !c.done(i):
+				line = lineNumber.Render(strings.Repeat("·", 8)) + "  " + c.name(i)
+			default:
+				line = c.sums[i][algorithm] + "  " + c.name(i)
+			}
+			lines = append(lines, line)
+		}
+	} else {
+		title = bold.Render("Verifying " + filepath.Base(c.sumsFile))
+		var ok, failed, missing int
+		for i, e := range c.expected {
+			status := lineNumber.Render("…      ")
+			if c.done(i) {
+				switch {
+				case os.IsNotExist(c.errs[i]):
+					status = treeKeyword.Render("MISSING")
+					missing++
+				case c.errs[i] != nil:
+					status = danger.Render("ERROR  ")
+					failed++
+				case c.sums[i][e.algorithm] == e.sum:
+					status = treeString.Render("OK     ")
+					ok++
+				default:
+					status = danger.Render("FAILED ")
+					failed++
+				}
+			}
+			lines = append(lines, status+" "+c.name(i))
+		}
+		if len(c.files) > 0 {
+			summary := fmt.Sprintf("%s: %d OK, %d failed, %d missing", plural(len(c.files), "file"), ok, failed, missing)
+			lines = append(lines, "", summary)
+		}
+	}
+
+	if c.message != "" {
+		lines = append(lines, "", bar.Render(c.message))
+	} else if done := c.computed(); done < len(c.files) {
+		lines = append(lines, "", lineNumber.Render(fmt.Sprintf("Computing %d/%d", done, len(c.files))))
+	} else if c.sumsFile == "" {
+		lines = append(lines, "", lineNumber.Render("tab: next algorithm, y: copy, esc: close"))
+	}
+
+	// Fit into the screen, keeping the end with the summary.
+	maxLines := max(1, m.termHeight-4)
+	if len(lines) > maxLines {
+		lines = lines[len(lines)-maxLines:]
+	}
+	for i, line := range lines {
+		lines[i] = ansi.Truncate(line, width, "…")
+	}
+	box := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(mainColor).
+		Padding(0, 1).
+		Render(ansi.Truncate(title, width, "…") + "\n" + strings.Join(lines, "\n"))
+	return lipgloss.Place(m.termWidth, m.termHeight, lipgloss.Center, lipgloss.Center, box)
+}
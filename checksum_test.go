@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSumFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "abc")
+	if err := os.WriteFile(path, []byte("abc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"SHA-256": "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		"SHA-1":   "a9993e364706816aba3e25717850c26c9cd0d89d",
+		"MD5":     "900150983cd24fb0d6963f7d28e17f72",
+		"BLAKE2b": "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923",
+	}
+
+	for algorithm, sum := range expected {
+		result, err := sumFile(context.Background(), path, algorithm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != sum {
+			t.Errorf("Failed: %v: %v != %v", algorithm, result, sum)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := sumFile(ctx, path, "SHA-256"); err != context.Canceled {
+		t.Errorf("Failed: cancelled sum returned %v", err)
+	}
+}
+
+func TestParseSums(t *testing.T) {
+	testCases := []struct {
+		content   string
+		fileName  string
+		name      string
+		algorithm string
+	}{
+		{"ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad  abc.txt", "SHA256SUMS", "abc.txt", "SHA-256"},
+		{"a9993e364706816aba3e25717850c26c9cd0d89d *dir/abc.bin", "SHA1SUMS", "dir/abc.bin", "SHA-1"},
+		{"900150983cd24fb0d6963f7d28e17f72  with space.txt", "sums.txt", "with space.txt", "MD5"}, // By length
+		{"SHA256 (abc.txt) = BA7816BF8F01CFEA414140DE5DAE2223B00361A396177A9CB410FF61F20015AD", "CHECKSUMS", "abc.txt", "SHA-256"},
+		{"# comment\n\nMD5 (a) = 900150983cd24fb0d6963f7d28e17f72", "CHECKSUMS", "a", "MD5"},
+	}
+
+	for _, tc := range testCases {
+		entries, err := parseSums(strings.NewReader(tc.content), tc.fileName)
+		if err != nil {
+			t.Errorf("Failed: %q: %v", tc.content, err)
+			continue
+		}
+		if len(entries) != 1 || entries[0].name != tc.name || entries[0].algorithm != tc.algorithm || entries[0].sum != strings.ToLower(entries[0].sum) {
+			t.Errorf("Failed: %q: %+v", tc.content, entries)
+		}
+	}
+
+	if _, err := parseSums(strings.NewReader("not a checksum"), "SHA256SUMS"); err == nil {
+		t.Errorf("Failed: invalid line is accepted")
+	}
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const (
+	ruleDirs = iota
+	ruleFiles
+)
+
+// chmodBits is the grid of the editor: rows of owner, group, other
+// and special bits, columns of read, write and execute.
+var chmodBits = [4][3]fs.FileMode{
+	{0400, 0200, 0100},
+	{0040, 0020, 0010},
+	{0004, 0002, 0001},
+	{fs.ModeSetuid, fs.ModeSetgid, fs.ModeSticky},
+}
+
+const chmodMask = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+
+// chmod is an overlay for editing permissions of files. Directories and
+// files have separate rules, so that applying recursively does not make
+// every file executable.
+type chmod struct {
+	files     []string
+	hasDirs   bool
+	hasFiles  bool
+	modes     [2]fs.FileMode // Indexed by ruleDirs and ruleFiles.
+	rule      int
+	row, col  int
+	octal     string // Octal digits typed so far.
+	recursive bool
+	applying  bool
+	message   string
+}
+
+type chmodMsg struct {
+	changed int
+	errs    []error
+}
+
+// openChmod edits permissions of selected files, or of the current file.
+func (m *model) openChmod() {
+	var files []string
+	for path := range m.selected {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+	if len(files) == 0 {
+		if filePath, ok := m.filePath(); ok {
+			files = append(files, filePath)
+		}
+	}
+	c := &chmod{files: files}
+	m.chmod = c
+	if len(files) == 0 {
+		c.message = "No files"
+		return
+	}
+
+	found := [2]bool{}
+	for _, file := range files {
+		fi, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		rule := ruleFiles
+		if fi.IsDir() {
+			rule = ruleDirs
+			c.hasDirs = true
+		} else {
+			c.hasFiles = true
+		}
+		if !found[rule] {
+			c.modes[rule] = fi.Mode() & chmodMask
+			found[rule] = true
+		}
+	}
+	// Derive the missing rule from the other one: directories need the
+	// execute bit to be entered, files usually do not.
+	switch {
+	case !found[ruleDirs] && found[ruleFiles]:
+		perm := c.modes[ruleFiles] & fs.ModePerm
+		c.modes[ruleDirs] = c.modes[ruleFiles] | perm&0444>>2
+	case !found[ruleFiles] && found[ruleDirs]:
+		c.modes[ruleFiles] = c.modes[ruleDirs] &^ (0111 | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+	case !found[ruleDirs] && !found[ruleFiles]:
+		c.modes = [2]fs.FileMode{0755, 0644}
+	}
+	if !c.hasDirs {
+		c.rule = ruleFiles
+	}
+}
+
+// showRules reports whether both rules matter, so both are shown.
+func (c *chmod) showRules() bool {
+	return c.hasDirs && (c.hasFiles || c.recursive)
+}
+
+func (m *model) updateChmod(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	c := m.chmod
+	if c.applying {
+		return m, nil
+	}
+	c.message = ""
+	switch {
+	case key.Matches(msg, keyForceQuit):
+		m.quitting = true
+		m.exitCode = 2
+		m.dontDoPendingDeletions()
+		return m, tea.Quit
+
+	case key.Matches(msg, keyQuit, keyQuitQ):
+		m.chmod = nil
+
+	case len(c.files) == 0:
+		m.chmod = nil
+
+	case key.Matches(msg, keyOpen):
+		c.applying = true
+		return m, c.apply()
+
+	case key.Matches(msg, keyUp, keyVimUp):
+		c.row = (c.row + len(chmodBits) - 1) % len(chmodBits)
+
+	case key.Matches(msg, keyDown, keyVimDown):
+		c.row = (c.row + 1) % len(chmodBits)
+
+	case key.Matches(msg, keyLeft, keyVimLeft):
+		c.col = (c.col + 2) % 3
+
+	case key.Matches(msg, keyRight, keyVimRight):
+		c.col = (c.col + 1) % 3
+
+	case key.Matches(msg, keyChmodToggle):
+		c.modes[c.rule] ^= chmodBits[c.row][c.col]
+		c.octal = ""
+
+	case key.Matches(msg, keyChmodRecursive):
+		if c.hasDirs {
+			c.recursive = !c.recursive
+		} else {
+			c.message = "No directories selected"
+		}
+
+	case key.Matches(msg, keyChmodRule):
+		if c.showRules() {
+			c.rule = 1 - c.rule
+			c.octal = ""
+		}
+
+	case key.Matches(msg, keyBack):
+		if len(c.octal) > 0 {
+			c.octal = c.octal[:len(c.octal)-1]
+			c.setOctal()
+		}
+
+	case len(msg.Runes) == 1 && msg.Runes[0] >= '0' && msg.Runes[0] <= '7':
+		if len(c.octal) == 4 {
+			c.octal = ""
+		}
+		c.octal += string(msg.Runes)
+		c.setOctal()
+	}
+	return m, nil
+}
+
+// setOctal sets the mode of the current rule from typed octal digits,
+// as soon as there are enough of them.
+func (c *chmod) setOctal() {
+	if len(c.octal) < 3 {
+		return
+	}
+	n, err := strconv.ParseUint(c.octal, 8, 32)
+	if err != nil {
+		return
+	}
+	mode := fs.FileMode(n) & fs.ModePerm
+	if n&04000 != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if n&02000 != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if n&01000 != 0 {
+		mode |= fs.ModeSticky
+	}
+	c.modes[c.rule] = mode
+}
+
+// apply changes permissions in background. Symlinks found while walking
+// recursively are not followed.
+func (c *chmod) apply() tea.Cmd {
+	files, modes, recursive := c.files, c.modes, c.recursive
+	return func() tea.Msg {
+		var msg chmodMsg
+		change := func(path string, isDir bool) {
+			mode := modes[ruleFiles]
+			if isDir {
+				mode = modes[ruleDirs]
+			}
+			if err := os.Chmod(path, mode); err != nil {
+				msg.errs = append(msg.errs, err)
+			} else {
+				msg.changed++
+			}
+		}
+		for _, file := range files {
+			fi, err := os.Stat(file)
+			if err != nil {
+				msg.errs = append(msg.errs, err)
+				continue
+			}
+			if !recursive || !fi.IsDir() {
+				change(file, fi.IsDir())
+				continue
+			}
+			// Change the directory after its content, so that removing
+			// permissions from it does not prevent the walk.
+			var dirs []string
+			_ = filepath.WalkDir(file, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					msg.errs = append(msg.errs, err)
+					return nil
+				}
+				switch {
+				case d.IsDir():
+					dirs = append(dirs, path)
+				case d.Type().IsRegular():
+					change(path, false)
+				}
+				return nil
+			})
+			for i := len(dirs) - 1; i >= 0; i-- {
+				change(dirs[i], true)
+			}
+		}
+		return msg
+	}
+}
+
+func (m *model) chmodDone(msg chmodMsg) {
+	c := m.chmod
+	if c == nil {
+		return
+	}
+	m.list()
+	if len(msg.errs) == 0 {
+		m.chmod = nil
+		return
+	}
+	c.applying = false
+	c.message = fmt.Sprintf("Changed %s, %s: %v", plural(msg.changed, "file"), plural(len(msg.errs), "error"), msg.errs[0])
+}
+
+func (m *model) chmodView() string {
+	c := m.chmod
+	width := m.termWidth - 4 // Border and padding.
+
+	title := "Permissions of "
+	if len(c.files) == 1 {
+		title += filepath.Base(c.files[0])
+	} else {
+		title += plural(len(c.files), "file")
+	}
+	lines := []string{bold.Render(title), ""}
+
+	if c.showRules() {
+		var tabs []string
+		for rule, name := range []string{"Directories", "Files"} {
+			if rule == c.rule {
+				tabs = append(tabs, cursor.Render(" "+name+" "))
+			} else {
+				tabs = append(tabs, " "+name+" ")
+			}
+		}
+		lines = append(lines, strings.Join(tabs, ""), "")
+	}
+
+	mode := c.modes[c.rule]
+	header := func(names ...string) string {
+		s := fmt.Sprintf("%-9s", "")
+		for _, name := range names {
+			s += fmt.Sprintf("%-8s", name)
+		}
+		return lineNumber.Render(strings.TrimRight(s, " "))
+	}
+	for row, name := range []string{"Owner", "Group", "Other", "Special"} {
+		switch row {
+		case 0:
+			lines = append(lines, header("read", "write", "exec"))
+		case 3:
+			lines = append(lines, header("setuid", "setgid", "sticky"))
+		}
+		s := fmt.Sprintf("%-9s", name)
+		for col, bit := range chmodBits[row] {
+			box := "[ ]"
+			if mode&bit != 0 {
+				box = "[x]"
+			}
+			if row == c.row && col == c.col {
+				box = cursor.Render(box)
+			}
+			s += box + strings.Repeat(" ", 5)
+		}
+		lines = append(lines, strings.TrimRight(s, " "))
+	}
+
+	octal := fmt.Sprintf("%04o", octalMode(mode))
+	if c.octal != "" {
+		octal = c.octal + lineNumber.Render(strings.Repeat("_", max(0, 3-len(c.octal))))
+	}
+	lines = append(lines, "", bold.Render(fmt.Sprintf("%-9s", "Octal"))+octal+"  "+modeString(mode)[1:])
+	if c.hasDirs {
+		recursive := "no"
+		if c.recursive {
+			recursive = "yes"
+		}
+		lines = append(lines, bold.Render(fmt.Sprintf("%-9s", "Recurse"))+recursive)
+	}
+
+	lines = append(lines, "")
+	switch {
+	case c.message != "":
+		lines = append(lines, bar.Render(c.message))
+	case c.applying:
+		lines = append(lines, lineNumber.Render("Changing permissions…"))
+	default:
+		help := "space: toggle, 0-7: octal, "
+		if c.hasDirs {
+			help += "R: recursive, "
+		}
+		if c.showRules() {
+			help += "tab: rule, "
+		}
+		lines = append(lines, lineNumber.Render(help+"enter: apply, esc: cancel"))
+	}
+
+	for i, line := range lines {
+		lines[i] = ansi.Truncate(line, width, "…")
+	}
+	box := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(mainColor).
+		Padding(0, 1).
+		Render(strings.Join(lines, "\n"))
+	return lipgloss.Place(m.termWidth, m.termHeight, lipgloss.Center, lipgloss.Center, box)
+}
+
+// octalMode returns mode as chmod(1) octal number, with special bits.
+func octalMode(mode fs.FileMode) uint32 {
+	n := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		n |= 04000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		n |= 02000
+	}
+	if mode&fs.ModeSticky != 0 {
+		n |= 01000
+	}
+	return n
+}
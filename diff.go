@@ -0,0 +1,865 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const (
+	diffContext = 3        // Unchanged lines around changes.
+	maxDiffCost = 1000     // Edits to search for, before giving up on a minimal diff.
+	maxDiffSize = 20 << 20 // Larger files are only compared byte by byte.
+)
+
+// diffOp is a line of a diff: kept, deleted from the left file or inserted
+// from the right one. Line indexes are -1 when not applicable.
+type diffOp struct {
+	kind byte // ' ', '-' or '+'.
+	a, b int
+}
+
+// diffLines finds edits turning a into b with Myers' algorithm.
+func diffLines(a, b []string) []diffOp {
+	return diffRange(a, b, 0, 0)
+}
+
+// diffRange diffs a and b, which start at lines offA and offB of whole
+// files. Common prefix and suffix are kept without searching.
+func diffRange(a, b []string, offA, offB int) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, max(len(a), len(b)))
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, diffOp{' ', offA + i, offB + i})
+	}
+	ops = append(ops, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], offA+prefix, offB+prefix)...)
+	for i := 0; i < suffix; i++ {
+		ops = append(ops, diffOp{' ', offA + len(a) - suffix + i, offB + len(b) - suffix + i})
+	}
+	return ops
+}
+
+// myers diffs a and b, which start at lines offA and offB of whole files.
+// If the diff costs more than maxDiffCost edits, the files are split by
+// splitDiff instead.
+func myers(a, b []string, offA, offB int) []diffOp {
+	// Compare numbers instead of strings.
+	ids := make(map[string]int)
+	intern := func(lines []string) []int {
+		out := make([]int, len(lines))
+		for i, line := range lines {
+			id, ok := ids[line]
+			if !ok {
+				id = len(ids)
+				ids[line] = id
+			}
+			out[i] = id
+		}
+		return out
+	}
+	x, y := intern(a), intern(b)
+	n, m := len(x), len(y)
+
+	// Furthest reaching x on each diagonal k, saved for every cost d, so
+	// that the path can be restored. Only diagonals -d-1..d+1 are saved.
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+	found := false
+	for d := 0; d <= n+m && d <= maxDiffCost && !found; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var i int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				i = v[offset+k+1]
+			} else {
+				i = v[offset+k-1] + 1
+			}
+			j := i - k
+			for i < n && j < m && x[i] == y[j] {
+				i++
+				j++
+			}
+			v[offset+k] = i
+			if i >= n && j >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	if !found {
+		return splitDiff(a, b, offA, offB)
+	}
+
+	var ops []diffOp
+
+	i, j := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		saved := trace[d]
+		at := func(k int) int { return saved[k+d+1] }
+		k := i - j
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevI := at(prevK)
+		prevJ := prevI - prevK
+		for i > prevI && j > prevJ {
+			i--
+			j--
+			ops = append(ops, diffOp{' ', offA + i, offB + j})
+		}
+		if d > 0 {
+			if i == prevI {
+				j--
+				ops = append(ops, diffOp{'+', -1, offB + j})
+			} else {
+				i--
+				ops = append(ops, diffOp{'-', offA + i, -1})
+			}
+		}
+	}
+	for l, r := 0, len(ops)-1; l < r; l, r = l+1, r-1 {
+		ops[l], ops[r] = ops[r], ops[l]
+	}
+	return ops
+}
+
+// splitDiff diffs parts of a and b between anchors: lines found once in
+// each of them, in the same order, like patience diff does. Without
+// anchors, all of a is replaced with all of b.
+func splitDiff(a, b []string, offA, offB int) []diffOp {
+	var ops []diffOp
+	i, j := 0, 0
+	for _, anchor := range anchors(a, b) {
+		ops = append(ops, diffRange(a[i:anchor.a], b[j:anchor.b], offA+i, offB+j)...)
+		ops = append(ops, diffOp{' ', offA + anchor.a, offB + anchor.b})
+		i, j = anchor.a+1, anchor.b+1
+	}
+	if i == 0 && j == 0 {
+		for i := range a {
+			ops = append(ops, diffOp{'-', offA + i, -1})
+		}
+		for j := range b {
+			ops = append(ops, diffOp{'+', -1, offB + j})
+		}
+		return ops
+	}
+	return append(ops, diffRange(a[i:], b[j:], offA+i, offB+j)...)
+}
+
+// anchors returns the longest sequence of lines unique in both a and b,
+// which are in the same order in a and b.
+func anchors(a, b []string) []diffOp {
+	count := make(map[string]int)
+	for _, line := range a {
+		count[line]++
+	}
+	inB := make(map[string]int)
+	for j, line := range b {
+		if count[line] == 1 {
+			if _, ok := inB[line]; ok {
+				inB[line] = -1 // Not unique in b.
+			} else {
+				inB[line] = j
+			}
+		}
+	}
+	var unique []diffOp
+	for i, line := range a {
+		if j, ok := inB[line]; ok && j >= 0 && count[line] == 1 {
+			unique = append(unique, diffOp{' ', i, j})
+		}
+	}
+
+	// Longest increasing subsequence of lines in b, with patience sorting:
+	// tails[k] is the unique line ending the best sequence of length k+1.
+	var tails []int
+	prev := make([]int, len(unique))
+	for n, u := range unique {
+		k := sort.Search(len(tails), func(k int) bool { return unique[tails[k]].b >= u.b })
+		prev[n] = -1
+		if k > 0 {
+			prev[n] = tails[k-1]
+		}
+		if k == len(tails) {
+			tails = append(tails, n)
+		} else {
+			tails[k] = n
+		}
+	}
+	if len(tails) == 0 {
+		return nil
+	}
+	seq := make([]diffOp, len(tails))
+	for n, k := tails[len(tails)-1], len(tails)-1; k >= 0; n, k = prev[n], k-1 {
+		seq[k] = unique[n]
+	}
+	return seq
+}
+
+// diffHunk is a range of ops with changes and some context around them.
+type diffHunk struct {
+	from, to int
+}
+
+func diffHunks(ops []diffOp, context int) []diffHunk {
+	var hunks []diffHunk
+	for i, op := range ops {
+		if op.kind == ' ' {
+			continue
+		}
+		from, to := max(0, i-context), min(len(ops), i+context+1)
+		if len(hunks) > 0 && from <= hunks[len(hunks)-1].to {
+			hunks[len(hunks)-1].to = to
+		} else {
+			hunks = append(hunks, diffHunk{from, to})
+		}
+	}
+	return hunks
+}
+
+// header returns unified diff header of the hunk, like "@@ -1,3 +1,4 @@".
+func (h diffHunk) header(ops []diffOp) string {
+	startA, startB, countA, countB := 0, 0, 0, 0
+	for _, op := range ops[h.from:h.to] {
+		if op.a >= 0 {
+			if countA == 0 {
+				startA = op.a + 1
+			}
+			countA++
+		}
+		if op.b >= 0 {
+			if countB == 0 {
+				startB = op.b + 1
+			}
+			countB++
+		}
+	}
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", startA, countA, startB, countB)
+}
+
+// diffRow is a line on the screen. In side-by-side layout, deleted and
+// inserted lines are paired, so a row may have both sides changed.
+type diffRow struct {
+	kind   byte // ' ', '-', '+', '~' for changed on both sides, '@' for hunk header.
+	a, b   int
+	header string
+}
+
+func diffRows(ops []diffOp, hunks []diffHunk, split bool) ([]diffRow, []int) {
+	var rows []diffRow
+	var starts []int
+	for _, h := range hunks {
+		starts = append(starts, len(rows))
+		rows = append(rows, diffRow{kind: '@', header: h.header(ops)})
+		for i := h.from; i < h.to; i++ {
+			op := ops[i]
+			if !split || op.kind == ' ' {
+				rows = append(rows, diffRow{kind: op.kind, a: op.a, b: op.b})
+				continue
+			}
+			// Pair deletions with insertions of the same change.
+			var deleted, inserted []int
+			for ; i < h.to && ops[i].kind != ' '; i++ {
+				if ops[i].kind == '-' {
+					deleted = append(deleted, ops[i].a)
+				} else {
+					inserted = append(inserted, ops[i].b)
+				}
+			}
+			i--
+			for j := 0; j < max(len(deleted), len(inserted)); j++ {
+				row := diffRow{kind: '~', a: -1, b: -1}
+				if j < len(deleted) {
+					row.a = deleted[j]
+				} else {
+					row.kind = '+'
+				}
+				if j < len(inserted) {
+					row.b = inserted[j]
+				} else {
+					row.kind = '-'
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows, starts
+}
+
+// dirDiffEntry is a file which is not the same in compared directories.
+type dirDiffEntry struct {
+	path   string // Relative to compared directories.
+	status byte   // '<' only in left, '>' only in right, '!' differs.
+}
+
+// compareDirs recursively lists files which differ in directories left
+// and right. A directory present on one side only is listed as a whole.
+func compareDirs(left, right string) ([]dirDiffEntry, error) {
+	var entries []dirDiffEntry
+	walk := func(root, other string, status byte, compare bool) error {
+		return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(root, path)
+			if rel == "." {
+				return nil
+			}
+			otherInfo, err := os.Lstat(filepath.Join(other, rel))
+			if err != nil {
+				entries = append(entries, dirDiffEntry{filepath.ToSlash(rel), status})
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !compare {
+				if d.IsDir() && !otherInfo.IsDir() {
+					return filepath.SkipDir // Already listed as different.
+				}
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if info.IsDir() && otherInfo.IsDir() {
+				return nil
+			}
+			same, err := sameFiles(path, info, filepath.Join(other, rel), otherInfo)
+			if err != nil {
+				return err
+			}
+			if !same {
+				entries = append(entries, dirDiffEntry{filepath.ToSlash(rel), '!'})
+			}
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+	}
+	if err := walk(left, right, '<', true); err != nil {
+		return nil, err
+	}
+	if err := walk(right, left, '>', false); err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].path < entries[j].path })
+	return entries, nil
+}
+
+// sameFiles compares files of the same type: targets of symlinks, or
+// content of regular files.
+func sameFiles(a string, infoA fs.FileInfo, b string, infoB fs.FileInfo) (bool, error) {
+	if infoA.Mode().Type() != infoB.Mode().Type() {
+		return false, nil
+	}
+	switch {
+	case infoA.Mode()&os.ModeSymlink != 0:
+		targetA, errA := os.Readlink(a)
+		targetB, errB := os.Readlink(b)
+		return errA == nil && errB == nil && targetA == targetB, nil
+	case !infoA.Mode().IsRegular():
+		return true, nil
+	case infoA.Size() != infoB.Size():
+		return false, nil
+	}
+
+	fa, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+	fb, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+	bufA, bufB := make([]byte, 64<<10), make([]byte, 64<<10)
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		if !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == io.EOF || errB == io.ErrUnexpectedEOF, nil
+		}
+		if errA != nil {
+			return false, errA
+		}
+		if errB != nil {
+			return false, errB
+		}
+	}
+}
+
+// diff is the view of differences between two files or directories.
+type diff struct {
+	id          int
+	left, right string
+	dirs        bool
+	loading     bool
+	err         error
+	binary      bool
+	a, b        []string
+	ops         []diffOp
+	hunks       []diffHunk
+	rows        []diffRow
+	starts      []int // Rows of hunk headers.
+	split       bool
+	top, x      int
+	height      int
+	entries     []dirDiffEntry
+	cursor      int
+	file        *diff // Diff of a file opened from directories comparison.
+	message     string
+	full        bool // Drawn on the whole screen instead of the preview pane.
+	preview     bool // Preview was shown before the diff was opened.
+}
+
+type diffMsg struct {
+	id      int
+	binary  bool
+	a, b    []string
+	ops     []diffOp
+	entries []dirDiffEntry
+	err     error
+}
+
+var diffId int
+
+func newDiff(left, right string, dirs, split bool) (*diff, tea.Cmd) {
+	diffId++
+	d := &diff{id: diffId, left: left, right: right, dirs: dirs, split: split, loading: true}
+	id := d.id
+	return d, func() tea.Msg {
+		msg := diffMsg{id: id}
+		if dirs {
+			msg.entries, msg.err = compareDirs(left, right)
+			return msg
+		}
+		msg.binary, msg.a, msg.b, msg.ops, msg.err = diffFiles(left, right)
+		return msg
+	}
+}
+
+// diffFiles reads and diffs text files. Binary or huge files are only
+// compared, and returned ops tell whether they differ.
+func diffFiles(left, right string) (bool, []string, []string, []diffOp, error) {
+	infoA, err := os.Stat(left)
+	if err != nil {
+		return false, nil, nil, nil, err
+	}
+	infoB, err := os.Stat(right)
+	if err != nil {
+		return false, nil, nil, nil, err
+	}
+	if infoA.Size() > maxDiffSize || infoB.Size() > maxDiffSize {
+		same, err := sameFiles(left, infoA, right, infoB)
+		return true, nil, nil, binaryOps(same), err
+	}
+	contentA, err := os.ReadFile(left)
+	if err != nil {
+		return false, nil, nil, nil, err
+	}
+	contentB, err := os.ReadFile(right)
+	if err != nil {
+		return false, nil, nil, nil, err
+	}
+	textA, encodingA := decodeText(contentA)
+	textB, encodingB := decodeText(contentB)
+	if encodingA == "" || encodingB == "" {
+		return true, nil, nil, binaryOps(bytes.Equal(contentA, contentB)), nil
+	}
+	a, b := splitLines(textA), splitLines(textB)
+	return false, a, b, diffLines(a, b), nil
+}
+
+func binaryOps(same bool) []diffOp {
+	if same {
+		return nil
+	}
+	return []diffOp{{'-', 0, -1}}
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffPair returns files to compare: the file picked with keyDiff and the
+// current one, or selected files.
+func (m *model) diffPair(current string) (string, string, bool) {
+	if m.diffLeft != "" && m.diffLeft != current {
+		return m.diffLeft, current, true
+	}
+	var selected []string
+	for path := range m.selected {
+		selected = append(selected, path)
+	}
+	sort.Strings(selected)
+	switch {
+	case len(selected) == 2:
+		return selected[0], selected[1], true
+	case len(selected) == 1 && selected[0] != current:
+		return selected[0], current, true
+	}
+	return "", "", false
+}
+
+func (m *model) openDiff(left, right string) tea.Cmd {
+	infoA, errA := os.Stat(left)
+	infoB, errB := os.Stat(right)
+	if err := errors.Join(errA, errB); err != nil {
+		m.message = err.Error()
+		return nil
+	}
+	if infoA.IsDir() != infoB.IsDir() {
+		m.message = "Cannot compare a file with a directory"
+		return nil
+	}
+	d, cmd := newDiff(left, right, infoA.IsDir(), false)
+	d.preview = m.previewMode
+	m.diff = d
+	if m.previewMode {
+		return cmd // Already in alt screen.
+	}
+	// The diff is drawn in the preview pane, show it until the diff is
+	// closed.
+	m.previewMode = true
+	return tea.Batch(tea.EnterAltScreen, cmd)
+}
+
+func (m *model) closeDiff() tea.Cmd {
+	d := m.diff
+	m.diff = nil
+	if d.preview {
+		return nil
+	}
+	m.previewMode = false
+	m.previewContent = ""
+	m.previewFocus = false
+	return tea.ExitAltScreen
+}
+
+func (m *model) diffDone(msg diffMsg) {
+	d := m.diff
+	if d != nil && d.file != nil {
+		d = d.file
+	}
+	if d == nil || d.id != msg.id {
+		return
+	}
+	d.loading = false
+	d.err = msg.err
+	d.binary = msg.binary
+	d.a, d.b, d.ops = msg.a, msg.b, msg.ops
+	d.entries = msg.entries
+	d.hunks = diffHunks(d.ops, diffContext)
+	d.rows, d.starts = diffRows(d.ops, d.hunks, d.split)
+}
+
+func (m *model) updateDiff(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if key.Matches(msg, keyForceQuit) {
+		m.quitting = true
+		m.exitCode = 2
+		m.dontDoPendingDeletions()
+		return m, tea.Quit
+	}
+
+	if key.Matches(msg, keyDiffFull) {
+		m.diff.full = !m.diff.full
+		return m, nil
+	}
+
+	d := m.diff
+	if d.file != nil {
+		if key.Matches(msg, keyQuit, keyQuitQ, keyBack) {
+			d.split = d.file.split
+			d.file = nil
+			return m, nil
+		}
+		d = d.file
+	}
+	d.message = ""
+
+	if d.dirs {
+		switch {
+		case key.Matches(msg, keyQuit, keyQuitQ, keyDiff):
+			return m, m.closeDiff()
+		case key.Matches(msg, keyUp, keyVimUp):
+			d.cursor = max(0, d.cursor-1)
+		case key.Matches(msg, keyDown, keyVimDown):
+			d.cursor = max(0, min(len(d.entries)-1, d.cursor+1))
+		case key.Matches(msg, keyPageUp):
+			d.cursor = max(0, d.cursor-d.height)
+		case key.Matches(msg, keyPageDown):
+			d.cursor = max(0, min(len(d.entries)-1, d.cursor+d.height))
+		case key.Matches(msg, keyHome, keyVimTop):
+			d.cursor = 0
+		case key.Matches(msg, keyEnd, keyVimBottom):
+			d.cursor = max(0, len(d.entries)-1)
+		case key.Matches(msg, keyOpen):
+			if d.cursor >= len(d.entries) || d.entries[d.cursor].status != '!' {
+				break
+			}
+			rel := filepath.FromSlash(d.entries[d.cursor].path)
+			left, right := filepath.Join(d.left, rel), filepath.Join(d.right, rel)
+			infoA, errA := os.Stat(left)
+			infoB, errB := os.Stat(right)
+			if errA != nil || errB != nil || !infoA.Mode().IsRegular() || !infoB.Mode().IsRegular() {
+				d.message = "Only regular files can be compared"
+				break
+			}
+			var cmd tea.Cmd
+			d.file, cmd = newDiff(left, right, false, d.split)
+			return m, cmd
+		}
+		return m, nil
+	}
+
+	switch {
+	case key.Matches(msg, keyQuit, keyQuitQ, keyDiff):
+		return m, m.closeDiff()
+	case key.Matches(msg, keyUp, keyVimUp):
+		d.scroll(-1)
+	case key.Matches(msg, keyDown, keyVimDown, keyOpen):
+		d.scroll(1)
+	case key.Matches(msg, keyPageUp, keyTop, keyBack):
+		d.scroll(-d.height)
+	case key.Matches(msg, keyPageDown, keyBottom, keyPreview):
+		d.scroll(d.height)
+	case key.Matches(msg, keyHome, keyVimTop):
+		d.top = 0
+	case key.Matches(msg, keyEnd, keyVimBottom):
+		d.top = max(0, len(d.rows)-d.height)
+	case key.Matches(msg, keyLeft, keyVimLeft):
+		d.x = max(0, d.x-horizontalScrollStep)
+	case key.Matches(msg, keyRight, keyVimRight):
+		d.x += horizontalScrollStep
+	case key.Matches(msg, keyLeftmost):
+		d.x = 0
+	case key.Matches(msg, keyNextHunk, keyNextMatch):
+		d.jump(1)
+	case key.Matches(msg, keyPrevHunk, keyPrevMatch):
+		d.jump(-1)
+	case key.Matches(msg, keyDiffSplit):
+		// Keep the same hunk on the screen.
+		hunk := d.hunk()
+		d.split = !d.split
+		d.rows, d.starts = diffRows(d.ops, d.hunks, d.split)
+		d.top = 0
+		if hunk > 0 {
+			d.top = d.starts[hunk-1]
+		}
+		d.scroll(0)
+	}
+	return m, nil
+}
+
+// scroll moves by n rows. Jumping to the last hunk may scroll past the end,
+// which is kept until scrolled up.
+func (d *diff) scroll(n int) {
+	d.top = max(0, min(d.top+n, max(len(d.rows)-d.height, d.top)))
+}
+
+// jump scrolls to the next or previous hunk.
+func (d *diff) jump(dir int) {
+	if dir > 0 {
+		for _, start := range d.starts {
+			if start > d.top {
+				d.top = start
+				return
+			}
+		}
+		d.message = "No more changes"
+		return
+	}
+	for i := len(d.starts) - 1; i >= 0; i-- {
+		if d.starts[i] < d.top {
+			d.top = d.starts[i]
+			return
+		}
+	}
+	d.message = "No more changes"
+}
+
+// hunk returns number of the hunk at the top of the screen, starting at 1.
+func (d *diff) hunk() int {
+	n := 0
+	for _, start := range d.starts {
+		if start <= d.top {
+			n++
+		}
+	}
+	return max(n, min(1, len(d.starts)))
+}
+
+// diffView renders the diff on the whole screen, or in the preview pane.
+func (m *model) diffView(width, height int) string {
+	d := m.diff
+	if d.file != nil {
+		d = d.file
+	}
+	d.height = max(1, height-2) // Subtract title and status lines.
+
+	title := bar.Render(ansi.Truncate(d.left+" ↔ "+d.right, width, "…"))
+	var lines []string
+	switch {
+	case d.loading:
+		lines = append(lines, lineNumber.Render("Comparing…"))
+	case d.err != nil:
+		lines = append(lines, warning.Render(d.err.Error()))
+	case d.dirs:
+		lines = d.dirsView(width)
+	case len(d.hunks) == 0:
+		lines = append(lines, warning.Render("Files are identical"))
+	case d.binary:
+		lines = append(lines, warning.Render("Binary files differ"))
+	default:
+		gutter := len(fmt.Sprint(max(len(d.a), len(d.b))))
+		for _, row := range d.rows[d.top:min(len(d.rows), d.top+d.height)] {
+			if d.split {
+				lines = append(lines, d.splitRow(row, gutter, width))
+			} else {
+				lines = append(lines, d.unifiedRow(row, gutter, width))
+			}
+		}
+	}
+	lines = strings.Split(strings.Join(lines, "\n"), "\n") // Warnings take several lines.
+	for len(lines) < d.height {
+		lines = append(lines, lineNumber.Render("~"))
+	}
+	return title + "\n" + strings.Join(lines, "\n") + "\n" + d.status(width)
+}
+
+func (d *diff) dirsView(width int) []string {
+	if len(d.entries) == 0 {
+		return []string{warning.Render("Directories are identical")}
+	}
+	top := max(0, min(d.cursor-d.height/2, len(d.entries)-d.height))
+	var lines []string
+	for i := top; i < min(len(d.entries), top+d.height); i++ {
+		e := d.entries[i]
+		var status string
+		switch e.status {
+		case '<':
+			status = diffRemoved.Render("only in left ")
+		case '>':
+			status = diffAdded.Render("only in right")
+		default:
+			status = treeKeyword.Render("differs      ")
+		}
+		name := ansi.Truncate(e.path, width-15, "…")
+		if i == d.cursor {
+			name = cursor.Render(name)
+		}
+		lines = append(lines, status+"  "+name)
+	}
+	return lines
+}
+
+// text returns the line prepared for the screen, scrolled horizontally.
+func (d *diff) text(lines []string, i, width int) string {
+	if i < 0 || i >= len(lines) || width <= 0 {
+		return ""
+	}
+	return ansi.Cut(sanitize(lines[i]), d.x, d.x+width)
+}
+
+func lineNo(i, gutter int) string {
+	if i < 0 {
+		return strings.Repeat(" ", gutter)
+	}
+	return fmt.Sprintf("%*d", gutter, i+1)
+}
+
+func (d *diff) unifiedRow(row diffRow, gutter, width int) string {
+	if row.kind == '@' {
+		return treeNumber.Render(row.header)
+	}
+	numbers := lineNumber.Render(lineNo(row.a, gutter) + " " + lineNo(row.b, gutter) + " ")
+	width -= 2*gutter + 3
+	switch row.kind {
+	case '-':
+		return numbers + diffRemoved.Render("-"+d.text(d.a, row.a, width))
+	case '+':
+		return numbers + diffAdded.Render("+"+d.text(d.b, row.b, width))
+	}
+	return numbers + " " + d.text(d.a, row.a, width)
+}
+
+func (d *diff) splitRow(row diffRow, gutter, width int) string {
+	if row.kind == '@' {
+		return treeNumber.Render(row.header)
+	}
+	half := (width - 1) / 2
+	cell := func(lines []string, i, width int, changed bool, style func(...string) string) string {
+		if i < 0 {
+			return strings.Repeat(" ", max(0, width))
+		}
+		text := d.text(lines, i, width-gutter-1)
+		text += strings.Repeat(" ", max(0, width-gutter-1-ansi.StringWidth(text)))
+		if changed {
+			text = style(text)
+		}
+		return lineNumber.Render(lineNo(i, gutter)+" ") + text
+	}
+	left := cell(d.a, row.a, half, row.kind != ' ', diffRemoved.Render)
+	right := cell(d.b, row.b, width-half-1, row.kind != ' ', diffAdded.Render)
+	return left + lineNumber.Render("│") + right
+}
+
+func (d *diff) status(width int) string {
+	var status string
+	switch {
+	case d.dirs:
+		status = fmt.Sprintf("%s  enter: diff file, f: full screen, q: close", plural(len(d.entries), "difference"))
+	case len(d.hunks) > 0 && !d.binary:
+		added, deleted := 0, 0
+		for _, op := range d.ops {
+			switch op.kind {
+			case '+':
+				added++
+			case '-':
+				deleted++
+			}
+		}
+		layout := "unified"
+		if d.split {
+			layout = "side by side"
+		}
+		status = fmt.Sprintf("hunk %d/%d  +%d -%d  %s  s: layout, n/N: next/prev hunk, f: full screen", d.hunk(), len(d.hunks), added, deleted, layout)
+	}
+	if d.message != "" {
+		status += "  " + d.message
+	}
+	return bar.Render(ansi.Truncate(status, width, "…"))
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	testCases := []struct {
+		a, b  string
+		edits int
+	}{
+		{"", "", 0},
+		{"a b c", "a b c", 0},
+		{"", "a b", 2},
+		{"a b", "", 2},
+		{"a b c", "a x c", 2},
+		{"a b c a b b a", "c b a b a c", 5},
+		{"x a b c", "a b c y", 2},
+	}
+
+	for _, tc := range testCases {
+		a, b := strings.Fields(tc.a), strings.Fields(tc.b)
+		ops := diffLines(a, b)
+		var gotA, gotB []string
+		edits := 0
+		for _, op := range ops {
+			if op.kind != ' ' {
+				edits++
+			}
+			if op.kind != '+' {
+				gotA = append(gotA, a[op.a])
+			}
+			if op.kind != '-' {
+				gotB = append(gotB, b[op.b])
+			}
+			if op.kind == ' ' && a[op.a] != b[op.b] {
+				t.Errorf("Failed: %q -> %q: kept %q != %q", tc.a, tc.b, a[op.a], b[op.b])
+			}
+		}
+		if strings.Join(gotA, " ") != tc.a || strings.Join(gotB, " ") != tc.b {
+			t.Errorf("Failed: %q -> %q: got %q -> %q", tc.a, tc.b, gotA, gotB)
+		}
+		if edits != tc.edits {
+			t.Errorf("Failed: %q -> %q: %d edits, expected %d", tc.a, tc.b, edits, tc.edits)
+		}
+	}
+}
+
+func TestDiffManyChanges(t *testing.T) {
+	// Too many changes for Myers' algorithm, lines between them are kept.
+	var a, b []string
+	for i := 0; i < 2000; i++ {
+		a = append(a, fmt.Sprintf("line %d", i))
+		if i >= 500 && i < 1700 && i%2 == 0 {
+			b = append(b, fmt.Sprintf("changed %d", i))
+		} else {
+			b = append(b, a[i])
+		}
+	}
+
+	edits := 0
+	i, j := 0, 0
+	for _, op := range diffLines(a, b) {
+		switch op.kind {
+		case ' ':
+			if op.a != i || op.b != j || a[i] != b[j] {
+				t.Fatalf("Failed: kept %d %d at %d %d", op.a, op.b, i, j)
+			}
+			i++
+			j++
+		case '-':
+			if op.a != i {
+				t.Fatalf("Failed: deleted %d at %d", op.a, i)
+			}
+			i++
+			edits++
+		case '+':
+			if op.b != j {
+				t.Fatalf("Failed: inserted %d at %d", op.b, j)
+			}
+			j++
+			edits++
+		}
+	}
+	if i != len(a) || j != len(b) {
+		t.Errorf("Failed: ended at %d %d", i, j)
+	}
+	if edits != 1200 {
+		t.Errorf("Failed: %d edits, expected 1200", edits)
+	}
+}
+
+func TestDiffHunks(t *testing.T) {
+	a := strings.Fields("1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 18 19 20")
+	b := append([]string(nil), a...)
+	b[1] = "two"
+	b[4] = "five"
+	b[17] = "eighteen"
+
+	ops := diffLines(a, b)
+	hunks := diffHunks(ops, 3)
+	if len(hunks) != 2 {
+		t.Fatalf("Failed: %d hunks, expected 2", len(hunks))
+	}
+	headers := []string{hunks[0].header(ops), hunks[1].header(ops)}
+	expected := []string{"@@ -1,8 +1,8 @@", "@@ -15,6 +15,6 @@"}
+	if !reflect.DeepEqual(headers, expected) {
+		t.Errorf("Failed: %q != %q", headers, expected)
+	}
+
+	rows, starts := diffRows(ops, hunks, true)
+	if !reflect.DeepEqual(starts, []int{0, 9}) || rows[2].kind != '~' {
+		t.Errorf("Failed: side by side rows %v, starts %v", rows, starts)
+	}
+}
+
+func TestCompareDirs(t *testing.T) {
+	left, right := t.TempDir(), t.TempDir()
+	write := func(root, name, content string) {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(left, "same.txt", "same")
+	write(right, "same.txt", "same")
+	write(left, "sub/changed.txt", "left")
+	write(right, "sub/changed.txt", "right")
+	write(left, "left.txt", "")
+	write(right, "new/right.txt", "")
+	write(right, "new/other.txt", "")
+
+	entries, err := compareDirs(left, right)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []dirDiffEntry{
+		{"left.txt", '<'},
+		{"new", '>'},
+		{"sub/changed.txt", '!'},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Failed: %v != %v", entries, expected)
+	}
+}
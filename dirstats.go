@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// dirSummary describes direct children of a directory.
+type dirSummary struct {
+	path       string
+	modTime    int64
+	dirs       int
+	files      int
+	links      int
+	others     int
+	size       int64     // Total size of files.
+	newest     time.Time // Newest modification of a child.
+	newestName string
+}
+
+// Directory preview is rendered on every frame, so keep the last summary.
+var dirSummaryCache dirSummary
+
+func summarizeDir(path string, modTime int64, files []os.DirEntry) dirSummary {
+	if dirSummaryCache.path == path && dirSummaryCache.modTime == modTime {
+		return dirSummaryCache
+	}
+	s := dirSummary{path: path, modTime: modTime}
+	for _, file := range files {
+		switch {
+		case file.Type()&os.ModeSymlink != 0:
+			s.links++
+		case file.IsDir():
+			s.dirs++
+		case file.Type().IsRegular():
+			s.files++
+		default:
+			s.others++
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		if info.Mode().IsRegular() {
+			s.size += info.Size()
+		}
+		if info.ModTime().After(s.newest) {
+			s.newest = info.ModTime()
+			s.newestName = file.Name()
+		}
+	}
+	dirSummaryCache = s
+	return s
+}
+
+// dirStats is the recursive size of a directory.
+type dirStats struct {
+	path    string
+	modTime int64
+	size    int64
+	files   int
+}
+
+type dirStatsMsg struct {
+	dirStats
+	id  int   // Walk that sent the message.
+	err error // Set if walk was cancelled.
+}
+
+// Recursive sizes of directories, per path. A directory's modification time
+// changes only when its direct children do, so deeper changes are noticed
+// after walk restarts.
+var dirStatsCache = make(map[string]dirStats)
+
+// walkDirStats sums sizes of all files under path, stopping when ctx is
+// cancelled.
+func walkDirStats(ctx context.Context, path string) (int64, int, error) {
+	var size int64
+	var files int
+	// WalkDir does not follow a symlink to directory given as root.
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil || !d.Type().IsRegular() {
+			// Skip unreadable directories, count the rest.
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+			files++
+		}
+		return nil
+	})
+	return size, files, err
+}
+
+// runDirStats starts computing recursive size of the directory in background,
+// cancelling the previous computation.
+func (m *model) runDirStats(path string) tea.Cmd {
+	stat, err := os.Stat(path)
+	if err != nil || !stat.IsDir() {
+		m.cancelDirStats()
+		return nil
+	}
+	modTime := stat.ModTime().UnixNano()
+	if s, ok := dirStatsCache[path]; ok && s.modTime == modTime {
+		m.cancelDirStats()
+		return nil
+	}
+	if m.dirStatsPath == path {
+		return nil
+	}
+	m.cancelDirStats()
+	ctx, cancel := context.WithCancel(context.Background())
+	m.dirStatsId++
+	id := m.dirStatsId
+	m.dirStatsPath = path
+	m.dirStatsCancel = cancel
+	return func() tea.Msg {
+		size, files, err := walkDirStats(ctx, path)
+		if err == nil {
+			err = ctx.Err() // Cancelled after the last file.
+		}
+		stats := dirStats{path: path, modTime: modTime, size: size, files: files}
+		return dirStatsMsg{dirStats: stats, id: id, err: err}
+	}
+}
+
+func (m *model) cancelDirStats() {
+	if m.dirStatsCancel != nil {
+		m.dirStatsCancel()
+	}
+	m.dirStatsPath = ""
+	m.dirStatsCancel = nil
+}
+
+// dirHeader renders summary of a directory shown above its files.
+func dirHeader(path string, modTime int64, files []os.DirEntry) []string {
+	s := summarizeDir(path, modTime, files)
+
+	var lines []string
+	add := func(name, value string) {
+		if value != "" {
+			lines = append(lines, bold.Render(fmt.Sprintf("%-12s", name))+value)
+		}
+	}
+
+	var items []string
+	for _, c := range []struct {
+		n    int
+		word string
+	}{{s.dirs, "dir"}, {s.files, "file"}, {s.links, "link"}, {s.others, "other"}} {
+		if c.n > 0 {
+			items = append(items, plural(c.n, c.word))
+		}
+	}
+	if len(items) == 0 {
+		items = append(items, plural(0, "file")) // Empty directory.
+	}
+	add("Items", strings.Join(items, ", "))
+	if s.files > 0 || len(files) == 0 {
+		add("Size", humanSize(s.size))
+	}
+	if !s.newest.IsZero() {
+		add("Modified", s.newest.Local().Format("2006-01-02 15:04")+" "+lineNumber.Render(s.newestName))
+	}
+
+	stats, ok := dirStatsCache[path]
+	if ok && stats.modTime == modTime {
+		add("Total", humanSize(stats.size)+" in "+plural(stats.files, "file"))
+	} else {
+		add("Total", lineNumber.Render("Calculating…"))
+	}
+	return lines
+}
@@ -0,0 +1,678 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	duBarWidth  = 20
+	duTickDelay = 100 * time.Millisecond
+)
+
+var errOtherFileSystem = errors.New("other file system")
+
+// duNode is a file or a directory in the disk usage tree.
+type duNode struct {
+	name     string // Full path for roots of the tree.
+	parent   *duNode
+	dir      bool
+	size     int64 // Recursive size of directories.
+	files    int   // Recursive number of files of directories.
+	children []*duNode
+	err      error // Error while reading directory.
+}
+
+func (n *duNode) path() string {
+	if n.parent == nil {
+		return n.name
+	}
+	return filepath.Join(n.parent.path(), n.name)
+}
+
+// update recomputes size of directory from its children, which are sorted by
+// size, and of all its parents.
+func (n *duNode) update() {
+	for ; n != nil; n = n.parent {
+		n.size, n.files = 0, 0
+		for _, child := range n.children {
+			n.size += child.size
+			if child.dir {
+				n.files += child.files
+			} else {
+				n.files++
+			}
+		}
+		sort.SliceStable(n.children, func(i, j int) bool {
+			return n.children[i].size > n.children[j].size
+		})
+	}
+}
+
+// Scanned trees per root path. Lives for the whole session, so leaving and
+// entering the disk usage mode, or going up the tree, needs no rescan.
+var duCache = make(map[string]*duNode)
+
+// duLookup finds node of path in scanned trees.
+func duLookup(path string) *duNode {
+	for rootPath, root := range duCache {
+		rel, err := filepath.Rel(rootPath, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		n := root
+		if rel != "." {
+			for _, name := range strings.Split(rel, string(filepath.Separator)) {
+				n = n.child(name)
+				if n == nil {
+					break
+				}
+			}
+		}
+		if n != nil {
+			return n
+		}
+	}
+	return nil
+}
+
+func (n *duNode) child(name string) *duNode {
+	for _, child := range n.children {
+		if child.name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// duInsert puts scanned tree of path into the cache, replacing trees under
+// it, or the node in a tree above it.
+func duInsert(path string, node *duNode) {
+	for rootPath := range duCache {
+		if isSubpath(rootPath, path) {
+			delete(duCache, rootPath)
+		}
+	}
+	if filepath.Dir(path) == path {
+		duCache[path] = node // Root of file system.
+		return
+	}
+	if parent := duLookup(filepath.Dir(path)); parent != nil && parent.dir {
+		node.name = filepath.Base(path)
+		node.parent = parent
+		replaced := false
+		for i, child := range parent.children {
+			if child.name == node.name {
+				parent.children[i] = node
+				replaced = true
+			}
+		}
+		if !replaced {
+			parent.children = append(parent.children, node)
+		}
+		parent.update()
+		return
+	}
+	duCache[path] = node
+}
+
+// duForget removes deleted file from scanned trees.
+func duForget(path string) {
+	n := duLookup(path)
+	if n == nil {
+		return
+	}
+	if n.parent == nil {
+		delete(duCache, path)
+		return
+	}
+	parent := n.parent
+	for i, child := range parent.children {
+		if child == n {
+			parent.children = append(parent.children[:i], parent.children[i+1:]...)
+			break
+		}
+	}
+	parent.update()
+}
+
+// isSubpath reports whether path is inside dir or is dir itself.
+func isSubpath(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// duKnown is a tree scanned before. Its usage is copied when the scan starts,
+// as deletions change the tree while the scan is running.
+type duKnown struct {
+	node  *duNode
+	size  int64
+	files int
+}
+
+// duScan walks a directory tree with a bounded number of goroutines.
+type duScan struct {
+	ctx    context.Context
+	sem    chan struct{}
+	wg     sync.WaitGroup
+	known  map[string]duKnown // Already scanned trees, not walked again.
+	mu     sync.Mutex
+	grafts map[*duNode]*duNode // Placeholders for known trees.
+	dev    uint64              // Device of the scanned directory, others are not entered.
+	inodes sync.Map            // Files with many hard links, counted once.
+	files  atomic.Int64
+	bytes  atomic.Int64
+}
+
+func (s *duScan) walk(node *duNode, path string) {
+	if s.ctx.Err() != nil {
+		return
+	}
+	entries, err := os.ReadDir(path)
+	node.err = err
+	for _, entry := range entries {
+		child := &duNode{name: entry.Name(), parent: node, dir: entry.IsDir()}
+		node.children = append(node.children, child)
+		childPath := filepath.Join(path, entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		dev, ino, links, ok := fileID(info)
+		if !child.dir {
+			// Hard links share the size, so count it once.
+			if !ok || links <= 1 {
+				child.size = info.Size()
+			} else if _, seen := s.inodes.LoadOrStore([2]uint64{dev, ino}, true); !seen {
+				child.size = info.Size()
+			}
+			s.files.Add(1)
+			s.bytes.Add(child.size)
+			continue
+		}
+		if ok && dev != s.dev {
+			// Like mounted disks, or /proc with huge files.
+			child.err = errOtherFileSystem
+			continue
+		}
+		if known, ok := s.known[childPath]; ok {
+			s.mu.Lock()
+			s.grafts[child] = known.node
+			s.mu.Unlock()
+			s.files.Add(int64(known.files))
+			s.bytes.Add(known.size)
+			continue
+		}
+		// Walk in a new goroutine if there is room, otherwise in this one.
+		select {
+		case s.sem <- struct{}{}:
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.walk(child, childPath)
+				<-s.sem
+			}()
+		default:
+			s.walk(child, childPath)
+		}
+	}
+}
+
+// finish puts known trees in place of placeholders and computes sizes. It
+// runs when the scan is done, so deletions made meanwhile are in the trees.
+func (s *duScan) finish(node *duNode) {
+	if known, ok := s.grafts[node]; ok {
+		node.children = known.children
+		node.err = known.err
+		for _, child := range node.children {
+			child.parent = node
+		}
+		node.size, node.files = known.size, known.files
+		return
+	}
+	children := node.children[:0]
+	for _, child := range node.children {
+		if known, ok := s.grafts[child]; ok && duCache[known.name] != known {
+			continue // Deleted while scanning.
+		}
+		if child.dir {
+			s.finish(child)
+		}
+		children = append(children, child)
+	}
+	node.children = children
+	node.size, node.files = 0, 0
+	for _, child := range node.children {
+		node.size += child.size
+		if child.dir {
+			node.files += child.files
+		} else {
+			node.files++
+		}
+	}
+	sort.SliceStable(node.children, func(i, j int) bool {
+		return node.children[i].size > node.children[j].size
+	})
+}
+
+// diskUsage is a full-screen mode listing directories sorted by size, like
+// ncdu.
+type diskUsage struct {
+	current  *duNode
+	selected map[*duNode]int // Cursor position per directory.
+	cursor   int
+	top      int
+	height   int
+
+	scanId     int
+	scanPath   string // Path being scanned.
+	scanSelect string // Path to select after scanning.
+	scan       *duScan
+	cancel     context.CancelFunc
+	started    time.Time
+}
+
+type (
+	duTickMsg int
+	duDoneMsg struct {
+		id   int
+		path string
+		node *duNode
+		err  error
+	}
+)
+
+var duScanId int
+
+func (m *model) openDiskUsage() tea.Cmd {
+	m.du = &diskUsage{selected: make(map[*duNode]int)}
+	var cmd tea.Cmd
+	if n := duLookup(m.path); n != nil {
+		m.du.current = n
+	} else {
+		cmd = m.du.startScan(m.path, "", false)
+	}
+	if m.previewMode {
+		return cmd // Already in alt screen.
+	}
+	return tea.Batch(cmd, tea.EnterAltScreen)
+}
+
+// closeDiskUsage leaves disk usage mode in the directory it shows.
+func (m *model) closeDiskUsage() tea.Cmd {
+	du := m.du
+	du.stopScan()
+	m.deleteCurrentFile = false
+	if n := du.current; n != nil {
+		if n.path() != m.path {
+			m.path = n.path()
+			m.list()
+		}
+		if sel := du.selection(m); sel != nil {
+			m.prevName = sel.name
+			m.findPrevName = true
+		}
+	}
+	m.du = nil
+	if m.previewMode {
+		return nil
+	}
+	return tea.ExitAltScreen
+}
+
+// startScan scans path in background and selects selectPath when done.
+// Trees scanned before under path are reused, unless it is a rescan.
+func (du *diskUsage) startScan(path, selectPath string, rescan bool) tea.Cmd {
+	du.stopScan()
+	ctx, cancel := context.WithCancel(context.Background())
+	duScanId++
+	id := duScanId
+	known := make(map[string]duKnown, len(duCache))
+	for rootPath, root := range duCache {
+		if !rescan && rootPath != path {
+			known[rootPath] = duKnown{node: root, size: root.size, files: root.files}
+		}
+	}
+	s := &duScan{
+		ctx:    ctx,
+		sem:    make(chan struct{}, runtime.NumCPU()*4),
+		known:  known,
+		grafts: make(map[*duNode]*duNode),
+	}
+	if info, err := os.Stat(path); err == nil {
+		s.dev, _, _, _ = fileID(info)
+	}
+	du.scan, du.cancel = s, cancel
+	du.scanId, du.scanPath, du.scanSelect = id, path, selectPath
+	du.started = time.Now()
+	scan := func() tea.Msg {
+		root := &duNode{name: path, dir: true}
+		s.walk(root, path)
+		s.wg.Wait()
+		return duDoneMsg{id: id, path: path, node: root, err: ctx.Err()}
+	}
+	return tea.Batch(scan, du.tick())
+}
+
+func (du *diskUsage) stopScan() {
+	if du.cancel != nil {
+		du.cancel()
+	}
+	du.scan, du.cancel, du.scanPath = nil, nil, ""
+}
+
+func (du *diskUsage) tick() tea.Cmd {
+	id := du.scanId
+	return tea.Tick(duTickDelay, func(time.Time) tea.Msg {
+		return duTickMsg(id)
+	})
+}
+
+func (m *model) diskUsageDone(msg duDoneMsg) {
+	du := m.du
+	if du == nil || du.scanId != msg.id || msg.err != nil {
+		return
+	}
+	du.scan.finish(msg.node)
+	duInsert(msg.path, msg.node)
+	du.stopScan()
+	if n := duLookup(msg.path); n != nil {
+		du.current = n
+		du.cursor = du.selected[n]
+	}
+	if du.scanSelect != "" {
+		if n := duLookup(du.scanSelect); n != nil {
+			du.selectNode(m, n)
+		}
+		du.scanSelect = ""
+	}
+}
+
+// entries returns children of the current directory, without the ones
+// waiting for deletion.
+func (du *diskUsage) entries(m *model) []*duNode {
+	if du.current == nil {
+		return nil
+	}
+	pending := m.pendingDeletions()
+	entries := make([]*duNode, 0, len(du.current.children))
+	for _, child := range du.current.children {
+		if !pending[child] {
+			entries = append(entries, child)
+		}
+	}
+	return entries
+}
+
+// pendingDeletions returns nodes of files waiting to be deleted.
+func (m *model) pendingDeletions() map[*duNode]bool {
+	pending := make(map[*duNode]bool, len(m.toBeDeleted))
+	for _, td := range m.toBeDeleted {
+		if n := duLookup(td.path); n != nil {
+			pending[n] = true
+		}
+	}
+	return pending
+}
+
+// usageOf returns size and number of files of node without files waiting
+// for deletion.
+func usageOf(n *duNode, pending map[*duNode]bool) (int64, int) {
+	size, files := n.size, n.files
+	for p := range pending {
+		if p == n {
+			return 0, 0
+		}
+		for a := p.parent; a != nil; a = a.parent {
+			if a == n {
+				size -= p.size
+				if p.dir {
+					files -= p.files
+				} else {
+					files--
+				}
+				break
+			}
+		}
+	}
+	return size, files
+}
+
+func sizeOf(n *duNode, pending map[*duNode]bool) int64 {
+	size, _ := usageOf(n, pending)
+	return size
+}
+
+func (du *diskUsage) selection(m *model) *duNode {
+	entries := du.entries(m)
+	if du.cursor < 0 || du.cursor >= len(entries) {
+		return nil
+	}
+	return entries[du.cursor]
+}
+
+func (du *diskUsage) selectNode(m *model, n *duNode) {
+	for i, e := range du.entries(m) {
+		if e == n {
+			du.cursor = i
+			return
+		}
+	}
+}
+
+func (du *diskUsage) enter(n *duNode) {
+	du.selected[du.current] = du.cursor
+	du.current = n
+	du.cursor = du.selected[n]
+	du.top = 0
+}
+
+func (m *model) updateDiskUsage(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	du := m.du
+
+	switch {
+	case key.Matches(msg, keyForceQuit):
+		m.quitting = true
+		m.exitCode = 2
+		m.dontDoPendingDeletions()
+		return m, tea.Quit
+
+	case key.Matches(msg, keyQuit, keyQuitQ, keyDiskUsage):
+		return m, m.closeDiskUsage()
+	}
+
+	if du.current == nil {
+		return m, nil // Still scanning.
+	}
+	entries := du.entries(m)
+
+	if !key.Matches(msg, keyDelete, keyFnDelete) {
+		m.deleteCurrentFile = false
+	}
+
+	switch {
+	case key.Matches(msg, keyUp, keyVimUp):
+		du.cursor--
+
+	case key.Matches(msg, keyDown, keyVimDown):
+		du.cursor++
+
+	case key.Matches(msg, keyPageUp):
+		du.cursor -= du.height
+
+	case key.Matches(msg, keyPageDown):
+		du.cursor += du.height
+
+	case key.Matches(msg, keyHome, keyVimTop, keyTop):
+		du.cursor = 0
+
+	case key.Matches(msg, keyEnd, keyVimBottom, keyBottom):
+		du.cursor = len(entries) - 1
+
+	case key.Matches(msg, keyOpen, keyRight, keyVimRight):
+		if n := du.selection(m); n != nil && n.dir {
+			du.enter(n)
+			return m, nil
+		}
+
+	case key.Matches(msg, keyBack, keyLeft, keyVimLeft):
+		from := du.current
+		if from.parent != nil {
+			du.selected[from] = du.cursor
+			du.current = from.parent
+			du.top = 0
+			du.selectNode(m, from)
+			return m, nil
+		}
+		// Scan the parent, reusing the tree scanned so far.
+		parent := filepath.Dir(from.name)
+		if parent != from.name && du.scanPath == "" {
+			du.selected[from] = du.cursor
+			return m, du.startScan(parent, from.name, false)
+		}
+
+	case key.Matches(msg, keyDuRescan):
+		if du.scanPath == "" {
+			return m, du.startScan(du.current.path(), "", true)
+		}
+
+	case key.Matches(msg, keyUndo):
+		if len(m.toBeDeleted) > 0 {
+			m.toBeDeleted = m.toBeDeleted[:len(m.toBeDeleted)-1]
+			m.list()
+		}
+
+	case key.Matches(msg, keyDelete, keyFnDelete):
+		n := du.selection(m)
+		if n == nil {
+			return m, nil
+		}
+		if !m.deleteCurrentFile {
+			m.deleteCurrentFile = true
+			return m, nil
+		}
+		m.deleteCurrentFile = false
+		m.toBeDeleted = append(m.toBeDeleted, toDelete{
+			path: n.path(),
+			at:   time.Now().Add(6 * time.Second),
+		})
+		m.list()
+		return m, tea.Tick(time.Second, func(time.Time) tea.Msg {
+			return toBeDeletedMsg(0)
+		})
+	}
+
+	du.cursor = max(0, min(du.cursor, len(du.entries(m))-1))
+	return m, nil
+}
+
+func (m *model) diskUsageView() string {
+	du := m.du
+	width, height := m.termWidth, m.termHeight
+	du.height = height - 2 // Subtract location and status bars.
+
+	pending := m.pendingDeletions()
+	location := du.scanPath
+	if du.current != nil {
+		location = du.current.path()
+	}
+	header := bar.Render(location)
+	if du.current != nil {
+		size, files := usageOf(du.current, pending)
+		header += " " + humanSize(size) + " in " + plural(files, "file")
+	}
+
+	var rows []string
+	entries := du.entries(m)
+	if du.current == nil {
+		rows = append(rows, "")
+	} else if len(entries) == 0 {
+		rows = append(rows, warning.Render("No files"))
+	}
+
+	du.cursor = max(0, min(du.cursor, len(entries)-1))
+	if du.cursor < du.top {
+		du.top = du.cursor
+	}
+	if du.cursor >= du.top+du.height {
+		du.top = du.cursor - du.height + 1
+	}
+
+	var largest int64
+	for _, e := range entries {
+		largest = max(largest, sizeOf(e, pending))
+	}
+	var total int64
+	if du.current != nil {
+		total = sizeOf(du.current, pending)
+	}
+	for i := du.top; i < len(entries) && len(rows) < du.height; i++ {
+		rows = append(rows, du.row(entries[i], sizeOf(entries[i], pending), total, largest, i == du.cursor, m.deleteCurrentFile, width))
+	}
+	for len(rows) < du.height {
+		rows = append(rows, "")
+	}
+
+	return header + "\n" + strings.Join(rows, "\n") + "\n" + m.diskUsageStatus()
+}
+
+// row renders size, share of the parent directory, bar graph relative to the
+// largest entry and name.
+func (du *diskUsage) row(n *duNode, size, total, largest int64, selected, deleting bool, width int) string {
+	var percent float64
+	if total > 0 {
+		percent = float64(size) * 100 / float64(total)
+	}
+	filled := 0
+	if largest > 0 {
+		filled = int(float64(size) * duBarWidth / float64(largest))
+	}
+	name := n.name
+	if n.dir {
+		name += fileSeparator
+	}
+	note := ""
+	if errors.Is(n.err, errOtherFileSystem) {
+		note = " (" + n.err.Error() + ")"
+	} else if n.err != nil {
+		note = " (unreadable)"
+	}
+	prefix := fmt.Sprintf("%8s %5.1f%% ", humanSize(size), percent)
+	graph := strings.Repeat("█", filled)
+	empty := strings.Repeat("░", duBarWidth-filled)
+
+	if selected {
+		line := prefix + graph + empty + " " + name + note
+		line += strings.Repeat(" ", max(0, width-strlen(line)))
+		if deleting {
+			return danger.Render(line)
+		}
+		return cursor.Render(line)
+	}
+	return prefix + graph + lineNumber.Render(empty) + " " + name + lineNumber.Render(note)
+}
+
+func (m *model) diskUsageStatus() string {
+	du := m.du
+	switch {
+	case len(m.toBeDeleted) > 0:
+		return m.deleteBar()
+	case du.scan != nil:
+		elapsed := time.Since(du.started).Truncate(100 * time.Millisecond)
+		return bar.Render(fmt.Sprintf("Scanning %s: %s in %s, %s", du.scanPath,
+			humanSize(du.scan.bytes.Load()), plural(int(du.scan.files.Load()), "file"), elapsed))
+	}
+	return lineNumber.Render("enter: open, backspace: up, d: delete, r: rescan, U: close")
+}
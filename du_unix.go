@@ -0,0 +1,18 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID returns device and inode of a file, and the number of hard links to
+// it.
+func fileID(info os.FileInfo) (dev, ino, links uint64, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, 0, false
+	}
+	return uint64(stat.Dev), uint64(stat.Ino), uint64(stat.Nlink), true
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+)
+
+func fileID(info os.FileInfo) (dev, ino, links uint64, ok bool) {
+	return 0, 0, 0, false
+}
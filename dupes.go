@@ -0,0 +1,617 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	dupesPartialSize = 4096 // Bytes hashed to tell apart files of the same size.
+	dupesTickDelay   = 200 * time.Millisecond
+	dupesColumnWidth = 30 // Narrowest column of a file in preview.
+)
+
+type dupeGroup struct {
+	size  int64
+	files []string // Sorted by path.
+}
+
+// dupes finds duplicate files in a directory tree in background, and shows
+// them in a list once found.
+type dupes struct {
+	path   string
+	id     int
+	cancel context.CancelFunc
+	stage  atomic.Value // Description of the current stage, for progress.
+	done   atomic.Int64 // Files processed in the current stage.
+	total  atomic.Int64 // Files to process in the current stage.
+	groups []dupeGroup  // Set when search is finished.
+	found  bool
+
+	// List of duplicates.
+	open    bool
+	cursor  int             // Index in rows.
+	top     int             // First visible row.
+	height  int             // Number of visible rows.
+	marked  map[string]bool // Files marked for deletion.
+	message string
+}
+
+type (
+	dupesTickMsg int
+	dupesDoneMsg struct {
+		id     int
+		groups []dupeGroup
+		err    error
+	}
+)
+
+var dupesId int
+
+// dupeRow is a line in the list: a group header if path is empty.
+type dupeRow struct {
+	group int
+	path  string
+}
+
+func (m *model) startDupes() tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	dupesId++
+	d := &dupes{path: m.path, id: dupesId, cancel: cancel, marked: make(map[string]bool)}
+	d.stage.Store("scanning")
+	m.dupes = d
+	find := func() tea.Msg {
+		groups, err := d.find(ctx)
+		return dupesDoneMsg{id: d.id, groups: groups, err: err}
+	}
+	return tea.Batch(find, d.tick())
+}
+
+func (d *dupes) tick() tea.Cmd {
+	id := d.id
+	return tea.Tick(dupesTickDelay, func(time.Time) tea.Msg {
+		return dupesTickMsg(id)
+	})
+}
+
+func (m *model) stopDupes() {
+	if m.dupes != nil {
+		m.dupes.cancel()
+	}
+	m.dupes = nil
+}
+
+// find groups files by size, then by hash of their beginning, and then by
+// hash of the whole content, so only files which may be duplicates are read.
+func (d *dupes) find(ctx context.Context) ([]dupeGroup, error) {
+	bySize := make(map[int64][]string)
+	seen := make(map[[2]uint64]bool)
+	err := filepath.WalkDir(d.path, func(path string, entry fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil || !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || info.Size() == 0 {
+			return nil
+		}
+		// Hard links to the same file are not copies.
+		if dev, ino, links, ok := fileID(info); ok && links > 1 {
+			if seen[[2]uint64{dev, ino}] {
+				return nil
+			}
+			seen[[2]uint64{dev, ino}] = true
+		}
+		bySize[info.Size()] = append(bySize[info.Size()], path)
+		d.done.Add(1)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []dupeGroup
+	for size, files := range bySize {
+		if len(files) > 1 {
+			groups = append(groups, dupeGroup{size: size, files: files})
+		}
+	}
+
+	d.stage.Store("comparing beginnings of")
+	groups, err = d.split(ctx, groups, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Small files were hashed whole already.
+	var large, small []dupeGroup
+	for _, g := range groups {
+		if g.size > dupesPartialSize {
+			large = append(large, g)
+		} else {
+			small = append(small, g)
+		}
+	}
+	d.stage.Store("comparing")
+	large, err = d.split(ctx, large, false)
+	if err != nil {
+		return nil, err
+	}
+	groups = append(small, large...)
+
+	for _, g := range groups {
+		sort.Strings(g.files)
+	}
+	// Groups wasting the most space go first.
+	sort.Slice(groups, func(i, j int) bool {
+		wi := groups[i].size * int64(len(groups[i].files)-1)
+		wj := groups[j].size * int64(len(groups[j].files)-1)
+		if wi != wj {
+			return wi > wj
+		}
+		return groups[i].files[0] < groups[j].files[0]
+	})
+	return groups, nil
+}
+
+// split divides groups by hash of files, hashing them in parallel, and drops
+// files without a copy.
+func (d *dupes) split(ctx context.Context, groups []dupeGroup, partial bool) ([]dupeGroup, error) {
+	var files []string
+	for _, g := range groups {
+		files = append(files, g.files...)
+	}
+	d.done.Store(0)
+	d.total.Store(int64(len(files)))
+
+	hashes := make([]string, len(files))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				hashes[i] = hashFile(ctx, files[i], partial)
+				d.done.Add(1)
+			}
+		}()
+	}
+	for i := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var result []dupeGroup
+	i := 0
+	for _, g := range groups {
+		byHash := make(map[string][]string)
+		var order []string
+		for _, file := range g.files {
+			h := hashes[i]
+			i++
+			if h == "" {
+				continue // Unreadable.
+			}
+			if _, ok := byHash[h]; !ok {
+				order = append(order, h)
+			}
+			byHash[h] = append(byHash[h], file)
+		}
+		for _, h := range order {
+			if len(byHash[h]) > 1 {
+				result = append(result, dupeGroup{size: g.size, files: byHash[h]})
+			}
+		}
+	}
+	return result, nil
+}
+
+// hashFile returns SHA-256 of the file, or of its beginning if partial.
+func hashFile(ctx context.Context, path string, partial bool) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if partial {
+		r = io.LimitReader(file, dupesPartialSize)
+	}
+	h := sha256.New()
+	buf := make([]byte, 64*1024)
+	for {
+		if ctx.Err() != nil {
+			return ""
+		}
+		n, err := r.Read(buf)
+		h.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return ""
+		}
+	}
+	return string(h.Sum(nil))
+}
+
+// status describes progress or result of the search for the status bar.
+func (d *dupes) status() string {
+	if !d.found {
+		stage := d.stage.Load().(string)
+		if stage == "scanning" {
+			return fmt.Sprintf("Finding duplicates: scanning, %s", plural(int(d.done.Load()), "file"))
+		}
+		return fmt.Sprintf("Finding duplicates: %s %d/%d files", stage, d.done.Load(), d.total.Load())
+	}
+	if len(d.groups) == 0 {
+		return "No duplicates found"
+	}
+	var wasted int64
+	for _, g := range d.groups {
+		wasted += g.size * int64(len(g.files)-1)
+	}
+	return fmt.Sprintf("Found %s of duplicates, %s wasted (D to show)", plural(len(d.groups), "group"), humanSize(wasted))
+}
+
+// rows returns lines of the list, without files waiting for deletion and
+// groups left with a single file.
+func (d *dupes) rows(m *model) []dupeRow {
+	pending := make(map[string]bool, len(m.toBeDeleted))
+	for _, td := range m.toBeDeleted {
+		pending[td.path] = true
+	}
+	var rows []dupeRow
+	for i, g := range d.groups {
+		var files []dupeRow
+		for _, file := range g.files {
+			if !pending[file] {
+				files = append(files, dupeRow{group: i, path: file})
+			}
+		}
+		if len(files) > 1 {
+			rows = append(rows, dupeRow{group: i})
+			rows = append(rows, files...)
+		}
+	}
+	return rows
+}
+
+func (d *dupes) selection(m *model) (string, bool) {
+	rows := d.rows(m)
+	if d.cursor < 0 || d.cursor >= len(rows) || rows[d.cursor].path == "" {
+		return "", false
+	}
+	return rows[d.cursor].path, true
+}
+
+// move moves cursor by n files, skipping group headers.
+func (d *dupes) move(rows []dupeRow, n int) {
+	if len(rows) == 0 {
+		return
+	}
+	step := 1
+	if n < 0 {
+		step, n = -1, -n
+	}
+	for ; n > 0; n-- {
+		next := d.cursor + step
+		for next >= 0 && next < len(rows) && rows[next].path == "" {
+			next += step
+		}
+		if next < 0 || next >= len(rows) {
+			break
+		}
+		d.cursor = next
+	}
+	d.clamp(rows)
+}
+
+// clamp keeps cursor on a file row.
+func (d *dupes) clamp(rows []dupeRow) {
+	d.cursor = max(0, min(d.cursor, len(rows)-1))
+	for d.cursor < len(rows) && rows[d.cursor].path == "" {
+		d.cursor++
+	}
+}
+
+// dupesForget removes deleted file from found duplicates.
+func (m *model) dupesForget(path string) {
+	if m.dupes == nil {
+		return
+	}
+	for i, g := range m.dupes.groups {
+		for j, file := range g.files {
+			if file == path {
+				m.dupes.groups[i].files = append(g.files[:j], g.files[j+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (m *model) updateDupes(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	d := m.dupes
+	d.message = ""
+	rows := d.rows(m)
+
+	switch {
+	case key.Matches(msg, keyForceQuit):
+		m.quitting = true
+		m.exitCode = 2
+		m.dontDoPendingDeletions()
+		return m, tea.Quit
+
+	case key.Matches(msg, keyQuit, keyQuitQ, keyDupes):
+		m.stopDupes()
+		if m.previewMode {
+			return m, nil
+		}
+		return m, tea.ExitAltScreen
+
+	case key.Matches(msg, keyUp, keyVimUp):
+		d.move(rows, -1)
+
+	case key.Matches(msg, keyDown, keyVimDown):
+		d.move(rows, 1)
+
+	case key.Matches(msg, keyPageUp):
+		d.move(rows, -d.height)
+
+	case key.Matches(msg, keyPageDown):
+		d.move(rows, d.height)
+
+	case key.Matches(msg, keyHome, keyVimTop, keyTop):
+		d.cursor = 0
+		d.clamp(rows)
+
+	case key.Matches(msg, keyEnd, keyVimBottom, keyBottom):
+		d.cursor = len(rows) - 1
+
+	case key.Matches(msg, keyDelete, keyFnDelete):
+		file, ok := d.selection(m)
+		if !ok {
+			break
+		}
+		if d.marked[file] {
+			delete(d.marked, file)
+			break
+		}
+		// Keep at least one file of each group.
+		group := rows[d.cursor].group
+		kept := 0
+		for _, row := range rows {
+			if row.group == group && row.path != "" && !d.marked[row.path] {
+				kept++
+			}
+		}
+		if kept <= 1 {
+			d.message = "Keep at least one copy"
+			break
+		}
+		d.marked[file] = true
+		d.move(rows, 1)
+
+	case key.Matches(msg, keyDupesMarkAll):
+		// Mark all but the first file of each group.
+		for i, row := range rows {
+			if row.path != "" && rows[i-1].path != "" {
+				d.marked[row.path] = true
+			} else if row.path != "" {
+				delete(d.marked, row.path)
+			}
+		}
+
+	case key.Matches(msg, keyOpen):
+		if len(d.marked) == 0 {
+			d.message = "No files marked"
+			break
+		}
+		at := time.Now().Add(6 * time.Second)
+		for _, row := range rows {
+			if d.marked[row.path] {
+				m.toBeDeleted = append(m.toBeDeleted, toDelete{path: row.path, at: at})
+			}
+		}
+		d.marked = make(map[string]bool)
+		m.list()
+		d.clamp(d.rows(m))
+		return m, tea.Tick(time.Second, func(time.Time) tea.Msg {
+			return toBeDeletedMsg(0)
+		})
+
+	case key.Matches(msg, keyUndo):
+		if len(m.toBeDeleted) > 0 {
+			m.toBeDeleted = m.toBeDeleted[:len(m.toBeDeleted)-1]
+			m.list()
+		}
+
+	case key.Matches(msg, keyPreviewFocus):
+		m.previewFocus = true
+	}
+
+	return m, nil
+}
+
+// dupesView shows groups of duplicates with preview of the selected file
+// next to them.
+func (m *model) dupesView() string {
+	d := m.dupes
+	rows := d.rows(m)
+	listWidth := m.termWidth / 2
+	d.height = m.termHeight - 2 // Subtract location and status bars.
+	d.clamp(rows)
+
+	if d.cursor < d.top {
+		d.top = d.cursor
+	}
+	if d.cursor >= d.top+d.height {
+		d.top = d.cursor - d.height + 1
+	}
+	// Show the header of the group at the top.
+	if d.top > 0 && d.cursor == d.top && rows[d.top-1].path == "" {
+		d.top--
+	}
+
+	location := d.path
+	if userHomeDir, err := os.UserHomeDir(); err == nil {
+		location = strings.Replace(location, userHomeDir, "~", 1)
+	}
+	var wasted int64
+	groups := 0
+	for _, row := range rows {
+		if row.path == "" {
+			groups++
+			wasted -= d.groups[row.group].size // One copy is kept.
+		} else {
+			wasted += d.groups[row.group].size
+		}
+	}
+	header := bar.Render(location) + " " + plural(groups, "group") + ", " + humanSize(wasted) + " wasted"
+
+	lines := make([]string, 0, d.height)
+	if len(rows) == 0 {
+		lines = append(lines, warning.Render("No duplicates"))
+	}
+	for i := d.top; i < len(rows) && len(lines) < d.height; i++ {
+		row := rows[i]
+		g := d.groups[row.group]
+		if row.path == "" {
+			count := 0
+			for _, r := range rows {
+				if r.group == row.group && r.path != "" {
+					count++
+				}
+			}
+			lines = append(lines, bold.Render(fmt.Sprintf("%s × %d", humanSize(g.size), count)))
+			continue
+		}
+		name, err := filepath.Rel(d.path, row.path)
+		if err != nil {
+			name = row.path
+		}
+		mark := "  "
+		if d.marked[row.path] {
+			mark = danger.Render("✗") + " "
+		}
+		name = truncateLeft(name, listWidth-2)
+		if i == d.cursor {
+			name = cursor.Render(name)
+		}
+		lines = append(lines, mark+name)
+	}
+	for len(lines) < d.height {
+		lines = append(lines, "")
+	}
+	list := header + "\n" + strings.Join(lines, "\n") + "\n" + m.dupesStatus()
+
+	// Preview of the selected file side by side with the other files of its
+	// group. Content is the same, so columns differ in location and time.
+	m.preview()
+	paneWidth := m.termWidth - listWidth - 3
+	copies := d.copies(rows)
+	columns := max(1, min(len(copies), paneWidth/dupesColumnWidth))
+	columnWidth := paneWidth / columns
+	content := m.previewView(columnWidth-1, m.previewHeight()-1)
+	panes := make([]string, 0, columns)
+	for i, file := range copies[:min(len(copies), columns)] {
+		name, err := filepath.Rel(d.path, file)
+		if err != nil {
+			name = file
+		}
+		name = truncateLeft(name, columnWidth-1)
+		modified := ""
+		if info, err := os.Stat(file); err == nil {
+			modified = info.ModTime().Local().Format("2006-01-02 15:04")
+		}
+		var pane string
+		if i == 0 {
+			nameBar := bar
+			if m.previewFocus {
+				nameBar = cursor
+			}
+			pane = nameBar.Render(name) + m.previewHeader() + m.previewStatus() + "\n" + lineNumber.Render(modified) + "\n" + content
+		} else {
+			body := content
+			if m.previewImage != "" {
+				body = "" // Terminal graphics are drawn once.
+			}
+			pane = name + "\n" + lineNumber.Render(modified) + "\n" + body
+		}
+		panes = append(panes, lipgloss.NewStyle().Width(columnWidth).MaxWidth(columnWidth).Render(pane))
+	}
+	previewStyle := previewPlain
+	if withBorder {
+		previewStyle = previewSplit
+	}
+
+	list = lipgloss.NewStyle().Width(listWidth).Render(list)
+	return m.eraseGraphics(m.previewImage) + lipgloss.JoinHorizontal(
+		lipgloss.Top,
+		list,
+		previewStyle.MaxHeight(m.termHeight).Render(lipgloss.JoinHorizontal(lipgloss.Top, panes...)),
+	)
+}
+
+// copies returns the selected file followed by the other files of its group.
+func (d *dupes) copies(rows []dupeRow) []string {
+	if d.cursor < 0 || d.cursor >= len(rows) || rows[d.cursor].path == "" {
+		return []string{""}
+	}
+	selected := rows[d.cursor]
+	files := []string{selected.path}
+	for _, row := range rows {
+		if row.group == selected.group && row.path != "" && row.path != selected.path {
+			files = append(files, row.path)
+		}
+	}
+	return files
+}
+
+func (m *model) dupesStatus() string {
+	d := m.dupes
+	switch {
+	case len(m.toBeDeleted) > 0:
+		return m.deleteBar()
+	case d.message != "":
+		return bar.Render(d.message)
+	}
+	return lineNumber.Render("d: mark, a: mark copies, enter: delete marked")
+}
+
+// truncateLeft cuts the beginning of s to fit width, keeping file names.
+func truncateLeft(s string, width int) string {
+	if strlen(s) <= width || width < 1 {
+		return s
+	}
+	r := []rune(s)
+	for len(r) > 0 && strlen(string(r)) > width-1 {
+		r = r[1:]
+	}
+	return "…" + string(r)
+}
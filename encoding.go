@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	xunicode "golang.org/x/text/encoding/unicode"
+)
+
+const tabWidth = 4
+
+// textEncoding is an encoding of a text file. Empty name means the file is
+// binary.
+type textEncoding struct {
+	name    string
+	decoder encoding.Encoding // Nil for UTF-8.
+	utf16   bool
+	bigEnd  bool
+}
+
+var (
+	encodingBinary   = textEncoding{}
+	encodingUTF8     = textEncoding{name: "UTF-8"}
+	encodingASCII    = textEncoding{name: "ASCII"}
+	encodingUTF8BOM  = textEncoding{name: "UTF-8 BOM"}
+	encodingUTF16LE  = textEncoding{name: "UTF-16LE", decoder: xunicode.UTF16(xunicode.LittleEndian, xunicode.IgnoreBOM), utf16: true}
+	encodingUTF16BE  = textEncoding{name: "UTF-16BE", decoder: xunicode.UTF16(xunicode.BigEndian, xunicode.IgnoreBOM), utf16: true, bigEnd: true}
+	encodingWestern  = textEncoding{name: "Windows-1252", decoder: charmap.Windows1252}
+	encodingCyrillic = textEncoding{name: "Windows-1251", decoder: charmap.Windows1251}
+	encodingKOI8R    = textEncoding{name: "KOI8-R", decoder: charmap.KOI8R}
+)
+
+// detectEncoding guesses encoding of the beginning of a file, which may be
+// cut at any byte.
+func detectEncoding(content []byte) textEncoding {
+	switch {
+	case bytes.HasPrefix(content, []byte("\xef\xbb\xbf")):
+		return encodingUTF8BOM
+	case bytes.HasPrefix(content, []byte("\xff\xfe")):
+		return encodingUTF16LE
+	case bytes.HasPrefix(content, []byte("\xfe\xff")):
+		return encodingUTF16BE
+	}
+
+	// UTF-16 without BOM has zero high bytes for ASCII characters, which are
+	// in every other byte.
+	sample := content[:min(len(content), 1024)&^1]
+	if len(sample) >= 4 {
+		var evenZeros, oddZeros int
+		for i := 0; i < len(sample); i += 2 {
+			if sample[i] == 0 {
+				evenZeros++
+			}
+			if sample[i+1] == 0 {
+				oddZeros++
+			}
+		}
+		pairs := len(sample) / 2
+		var e textEncoding
+		switch {
+		case oddZeros*10 > pairs*4 && evenZeros*20 < pairs:
+			e = encodingUTF16LE
+		case evenZeros*10 > pairs*4 && oddZeros*20 < pairs:
+			e = encodingUTF16BE
+		}
+		// Binary data may have such zeros too, but not decode to text.
+		if e.name != "" && !hasControls(e.decode(sample)) {
+			return e
+		}
+	}
+
+	if bytes.IndexByte(content, 0) >= 0 {
+		return encodingBinary
+	}
+	if utf8.Valid(trimIncompleteRune(content)) {
+		for _, b := range content {
+			if b >= utf8.RuneSelf {
+				return encodingUTF8
+			}
+		}
+		return encodingASCII
+	}
+	return detectLegacyEncoding(content)
+}
+
+// trimIncompleteRune cuts a rune at the end, which was split by reading only
+// the beginning of a file.
+func trimIncompleteRune(b []byte) []byte {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return b[:i]
+			}
+			break
+		}
+	}
+	return b
+}
+
+// hasControls reports whether more than 10% of text are control characters
+// not used in text files.
+func hasControls(text string) bool {
+	var controls, total int
+	for _, r := range text {
+		total++
+		if r < 0x20 && r != '\n' && r != '\r' && r != '\t' && r != '\f' && r != 0x1b {
+			controls++
+		}
+	}
+	return controls*10 > total
+}
+
+// detectLegacyEncoding tells apart 8-bit encodings by the letters they use.
+// Text in a Latin script has mostly ASCII letters, with occasional accented
+// ones. Text in Cyrillic has mostly non-ASCII ones.
+func detectLegacyEncoding(content []byte) textEncoding {
+	if hasControls(string(content)) {
+		return encodingBinary
+	}
+	var letters, high int
+	var upper, lower int // Bytes in 0xC0-0xDF and 0xE0-0xFF.
+	for _, b := range content {
+		switch {
+		case b >= 0xe0:
+			lower++
+			high++
+		case b >= 0xc0:
+			upper++
+			high++
+		case b >= 0x80:
+			high++
+		case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z':
+			letters++
+		}
+	}
+	if high > letters {
+		// Lowercase letters, which are the most common, are in 0xE0-0xFF in
+		// Windows-1251, but in 0xC0-0xDF in KOI8-R.
+		if upper > lower {
+			return encodingKOI8R
+		}
+		return encodingCyrillic
+	}
+	return encodingWestern
+}
+
+// decode converts text to UTF-8.
+func (e textEncoding) decode(b []byte) string {
+	if e.decoder == nil {
+		return strings.TrimPrefix(string(b), "\ufeff")
+	}
+	out, err := e.decoder.NewDecoder().Bytes(b)
+	if err != nil {
+		return string(b)
+	}
+	return strings.TrimPrefix(string(out), "\ufeff")
+}
+
+// newline returns "\n" in the encoding. In UTF-16 it is a whole code unit,
+// so text split at it has the same lines as the decoded text.
+func (e textEncoding) newline() []byte {
+	switch {
+	case e.utf16 && e.bigEnd:
+		return []byte("\x00\n")
+	case e.utf16:
+		return []byte("\n\x00")
+	}
+	return []byte("\n")
+}
+
+// decodeText detects encoding of content and converts it to UTF-8. Returns
+// empty encoding name for binary content.
+func decodeText(content []byte) (string, string) {
+	e := detectEncoding(content)
+	if e.name == "" {
+		return "", ""
+	}
+	return e.decode(content), e.name
+}
+
+// sanitize prepares text for the terminal: tabs are expanded according to
+// display width of characters, and control characters, which could mess up
+// the screen, are shown escaped.
+func sanitize(text string) string {
+	return sanitizeText(text, false)
+}
+
+// sanitizeColored is like sanitize, but keeps escape sequences setting colors
+// and text styles, for output of commands.
+func sanitizeColored(text string) string {
+	return sanitizeText(text, true)
+}
+
+func sanitizeText(text string, colors bool) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	column := 0
+	for i := 0; i < len(text); {
+		if colors && text[i] == 0x1b {
+			if n := sgrLength(text[i:]); n > 0 {
+				b.WriteString(text[i : i+n])
+				i += n
+				continue
+			}
+		}
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+		switch {
+		case r == '\n':
+			b.WriteByte('\n')
+			column = 0
+		case r == '\t':
+			n := tabWidth - column%tabWidth
+			b.WriteString(strings.Repeat(" ", n))
+			column += n
+		case r == '\r':
+		case r < 0x20 || r == 0x7f:
+			// Caret notation, like ^[ for escape.
+			b.WriteByte('^')
+			b.WriteRune(r ^ 0x40)
+			column += 2
+		case unicode.IsControl(r) || unicode.Is(unicode.Bidi_Control, r):
+			s := fmt.Sprintf("<U+%04X>", r)
+			b.WriteString(s)
+			column += len(s)
+		default:
+			b.WriteRune(r)
+			column += runewidth.RuneWidth(r)
+		}
+	}
+	return b.String()
+}
+
+// sgrLength returns length of "Select Graphic Rendition" escape sequence at
+// the start of s, like "\x1b[1;31m", or zero.
+func sgrLength(s string) int {
+	if !strings.HasPrefix(s, "\x1b[") {
+		return 0
+	}
+	for i := 2; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == 'm':
+			return i + 1
+		case c != ';' && c != ':' && (c < '0' || c > '9'):
+			return 0
+		}
+	}
+	return 0
+}
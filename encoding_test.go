@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	testCases := []struct {
+		content  string
+		expected string
+		text     string
+	}{
+		{"hello\n", "ASCII", "hello\n"},
+		{"Grüße\n", "UTF-8", "Grüße\n"},
+		{"\xef\xbb\xbfhi", "UTF-8 BOM", "hi"},
+		{"Gr\xc3", "UTF-8", "Gr\xc3"},                                     // Rune cut at the end
+		{"\xff\xfeh\x00i\x00", "UTF-16LE", "hi"},                          // BOM
+		{"\xfe\xff\x00h\x00i", "UTF-16BE", "hi"},                          // BOM
+		{"h\x00e\x00l\x00l\x00o\x00", "UTF-16LE", "hello"},                // No BOM
+		{"\x00h\x00e\x00l\x00l\x00o", "UTF-16BE", "hello"},                // No BOM
+		{"Gr\xfc\xdfe aus K\xf6ln\n", "Windows-1252", "Grüße aus Köln\n"}, // Latin-1
+		{"\xcf\xf0\xe8\xe2\xe5\xf2, \xec\xe8\xf0", "Windows-1251", "Привет, мир"},
+		{"\xf0\xd2\xc9\xd7\xc5\xd4, \xcd\xc9\xd2", "KOI8-R", "Привет, мир"},
+		{"\x7fELF\x02\x01\x01\x00\x00\x00", "", ""}, // Binary
+	}
+
+	for _, tc := range testCases {
+		text, encoding := decodeText([]byte(tc.content))
+		if encoding != tc.expected || text != tc.text {
+			t.Errorf("Failed: %q: %v %q != %v %q", tc.content, encoding, text, tc.expected, tc.text)
+		}
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	testCases := []struct {
+		text     string
+		expected string
+	}{
+		{"a\tb", "a   b"},
+		{"日本\tx", "日本    x"}, // Wide characters take two columns
+		{"ab\tc\n\td", "ab  c\n    d"},
+		{"line\r\n", "line\n"},
+		{"\x1b[31mred", "^[[31mred"},
+		{"\x00\x7f", "^@^?"},
+		{"a\u0085b", "a<U+0085>b"},
+		{"\u202eevil", "<U+202E>evil"},
+	}
+
+	for _, tc := range testCases {
+		result := sanitize(tc.text)
+		if result != tc.expected {
+			t.Errorf("Failed: %q != %q", result, tc.expected)
+		}
+	}
+}
+
+func TestSanitizeColored(t *testing.T) {
+	testCases := []struct {
+		text     string
+		expected string
+	}{
+		{"\x1b[1;31mred\x1b[0m\tx", "\x1b[1;31mred\x1b[0m x"},
+		{"\x1b[38;2;0;112;32mgo\x1b[m", "\x1b[38;2;0;112;32mgo\x1b[m"},
+		{"\x1b[2Jclear", "^[[2Jclear"},
+		{"\x1b]0;title\x07", "^[]0;title^G"},
+	}
+
+	for _, tc := range testCases {
+		result := sanitizeColored(tc.text)
+		if result != tc.expected {
+			t.Errorf("Failed: %q != %q", result, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"debug/buildinfo"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// maxInterpreterSize limits the read of the interpreter path, which is
+// much shorter in real files.
+const maxInterpreterSize = 4096
+
+var errNotExecutable = errors.New("not an executable")
+
+// executableFormat detects executables and libraries by magic bytes: "elf",
+// "macho", "pe", or empty string.
+func executableFormat(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	head := make([]byte, 64)
+	n, _ := io.ReadFull(file, head)
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, []byte("\x7fELF")):
+		return "elf"
+	case bytes.HasPrefix(head, []byte("\xfe\xed\xfa\xce")), bytes.HasPrefix(head, []byte("\xfe\xed\xfa\xcf")),
+		bytes.HasPrefix(head, []byte("\xce\xfa\xed\xfe")), bytes.HasPrefix(head, []byte("\xcf\xfa\xed\xfe")):
+		return "macho"
+	case len(head) == 64 && bytes.HasPrefix(head, []byte("MZ")):
+		// "MZ" alone is too weak, check signature of the PE header as well.
+		signature := make([]byte, 4)
+		if _, err := file.ReadAt(signature, int64(le32(head[0x3c:0x40]))); err == nil && string(signature) == "PE\x00\x00" {
+			return "pe"
+		}
+	}
+	return ""
+}
+
+type executableEntry struct {
+	path    string
+	modTime int64
+	content string
+	info    string
+	err     error
+}
+
+// Description of the last executable. Build info of Go binaries is searched
+// for in data sections, which takes a while in big binaries.
+var executableCache executableEntry
+
+// previewExecutable describes an executable: architecture, linking, needed
+// libraries, sections, and for Go binaries the build info. Returns
+// errNotExecutable for other files.
+func previewExecutable(path string) (string, string, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return "", "", err
+	}
+	c := &executableCache
+	if c.path == path && c.modTime == stat.ModTime().UnixNano() {
+		return c.content, c.info, c.err
+	}
+	*c = executableEntry{path: path, modTime: stat.ModTime().UnixNano()}
+
+	var d *executableDescription
+	switch executableFormat(path) {
+	case "elf":
+		d, err = describeELF(path)
+	case "macho":
+		d, err = describeMachO(path)
+	case "pe":
+		d, err = describePE(path)
+	default:
+		err = errNotExecutable
+	}
+	if err != nil {
+		c.err = err
+		return "", "", err
+	}
+	d.goBuildInfo(path)
+	c.content, c.info = d.render(), d.summary
+	return c.content, c.info, nil
+}
+
+type section struct {
+	name string
+	size uint64
+}
+
+type executableDescription struct {
+	summary    string     // Like "ELF 64-bit x86-64 executable".
+	fields     [][]string // Name and value pairs.
+	libraries  []string
+	sections   []section
+	goFields   [][]string
+	goDeps     []string
+	goSettings []string
+}
+
+func (d *executableDescription) add(name, value string) {
+	if value != "" {
+		d.fields = append(d.fields, []string{name, value})
+	}
+}
+
+func describeELF(path string) (*executableDescription, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	d := &executableDescription{}
+	bits := "32-bit"
+	if f.Class == elf.ELFCLASS64 {
+		bits = "64-bit"
+	}
+	arch := strings.ReplaceAll(strings.ToLower(strings.TrimPrefix(f.Machine.String(), "EM_")), "_", "-")
+	kind := map[elf.Type]string{
+		elf.ET_REL:  "relocatable",
+		elf.ET_EXEC: "executable",
+		elf.ET_DYN:  "shared object",
+		elf.ET_CORE: "core dump",
+	}[f.Type]
+
+	var interpreter string
+	for _, p := range f.Progs {
+		// Sizes in headers may be anything, check them before reading.
+		if p.Type == elf.PT_INTERP && p.Off+p.Filesz >= p.Off && p.Off+p.Filesz <= uint64(stat.Size()) {
+			data := make([]byte, min(p.Filesz, maxInterpreterSize))
+			if _, err := p.ReadAt(data, 0); err == nil {
+				interpreter = strings.TrimRight(string(data), "\x00")
+			}
+		}
+	}
+	// Position independent executables are shared objects with an
+	// interpreter.
+	if f.Type == elf.ET_DYN && interpreter != "" {
+		kind = "PIE executable"
+	}
+	d.libraries, _ = f.ImportedLibraries()
+	linking := "static"
+	if interpreter != "" || len(d.libraries) > 0 {
+		linking = "dynamic"
+	}
+
+	d.summary = fmt.Sprintf("ELF %s %s %s", bits, arch, kind)
+	d.add("Type", kind)
+	endian := "little-endian"
+	if f.Data == elf.ELFDATA2MSB {
+		endian = "big-endian"
+	}
+	d.add("Arch", fmt.Sprintf("%s, %s, %s", arch, bits, endian))
+	d.add("OS/ABI", strings.TrimPrefix(f.OSABI.String(), "ELFOSABI_"))
+	d.add("Linking", linking)
+	d.add("Interpreter", interpreter)
+	if f.Entry != 0 {
+		d.add("Entry", fmt.Sprintf("%#x", f.Entry))
+	}
+	if f.Section(".symtab") == nil {
+		d.add("Symbols", "stripped")
+	} else {
+		d.add("Symbols", "not stripped")
+	}
+	for _, s := range f.Sections {
+		if s.Name != "" && s.Type != elf.SHT_NULL {
+			d.sections = append(d.sections, section{s.Name, s.Size})
+		}
+	}
+	return d, nil
+}
+
+func describeMachO(path string) (*executableDescription, error) {
+	f, err := macho.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	d := &executableDescription{}
+	arch := strings.ToLower(strings.TrimPrefix(f.Cpu.String(), "Cpu"))
+	kind := strings.ToLower(f.Type.String())
+	d.summary = fmt.Sprintf("Mach-O %s %s", arch, kind)
+	d.add("Type", kind)
+	d.add("Arch", arch)
+	d.libraries, _ = f.ImportedLibraries()
+	for _, s := range f.Sections {
+		d.sections = append(d.sections, section{s.Seg + "," + s.Name, s.Size})
+	}
+	return d, nil
+}
+
+func describePE(path string) (*executableDescription, error) {
+	f, err := pe.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	d := &executableDescription{}
+	arch, ok := map[uint16]string{
+		pe.IMAGE_FILE_MACHINE_I386:  "x86",
+		pe.IMAGE_FILE_MACHINE_AMD64: "x86-64",
+		pe.IMAGE_FILE_MACHINE_ARMNT: "arm",
+		pe.IMAGE_FILE_MACHINE_ARM64: "arm64",
+	}[f.Machine]
+	if !ok {
+		arch = fmt.Sprintf("machine %#x", f.Machine)
+	}
+	kind := "executable"
+	if f.Characteristics&pe.IMAGE_FILE_DLL != 0 {
+		kind = "DLL"
+	}
+	d.summary = fmt.Sprintf("PE %s %s", arch, kind)
+	d.add("Type", kind)
+	d.add("Arch", arch)
+	d.libraries, _ = f.ImportedLibraries()
+	for _, s := range f.Sections {
+		d.sections = append(d.sections, section{s.Name, uint64(s.Size)})
+	}
+	return d, nil
+}
+
+// goBuildInfo adds module path, Go version and dependencies embedded into Go
+// binaries.
+func (d *executableDescription) goBuildInfo(path string) {
+	info, err := buildinfo.ReadFile(path)
+	if err != nil {
+		return
+	}
+	d.summary += " (Go)"
+	d.goFields = append(d.goFields, []string{"Go", info.GoVersion})
+	if info.Path != "" {
+		d.goFields = append(d.goFields, []string{"Path", info.Path})
+	}
+	if info.Main.Path != "" {
+		d.goFields = append(d.goFields, []string{"Module", strings.TrimSpace(info.Main.Path + " " + info.Main.Version)})
+	}
+	for _, dep := range info.Deps {
+		line := dep.Path + " " + dep.Version
+		if dep.Replace != nil {
+			line += " => " + dep.Replace.Path + " " + dep.Replace.Version
+		}
+		d.goDeps = append(d.goDeps, line)
+	}
+	for _, s := range info.Settings {
+		d.goSettings = append(d.goSettings, s.Key+"="+s.Value)
+	}
+}
+
+func (d *executableDescription) render() string {
+	var lines []string
+	field := func(name, value string) {
+		lines = append(lines, bold.Render(fmt.Sprintf("%-12s", name))+value)
+	}
+	heading := func(title string) {
+		lines = append(lines, "", bold.Render(title))
+	}
+
+	for _, f := range d.fields {
+		field(f[0], f[1])
+	}
+	for _, f := range d.goFields {
+		field(f[0], f[1])
+	}
+
+	if len(d.libraries) > 0 {
+		heading(fmt.Sprintf("Libraries (%d)", len(d.libraries)))
+		lines = append(lines, d.libraries...)
+	}
+	if len(d.sections) > 0 {
+		heading(fmt.Sprintf("Sections (%d)", len(d.sections)))
+		for _, s := range d.sections {
+			lines = append(lines, fmt.Sprintf("%-24s %8s", s.name, humanSize(int64(s.size))))
+		}
+	}
+	if len(d.goDeps) > 0 {
+		heading(fmt.Sprintf("Dependencies (%d)", len(d.goDeps)))
+		lines = append(lines, d.goDeps...)
+	}
+	if len(d.goSettings) > 0 {
+		heading("Build settings")
+		lines = append(lines, d.goSettings...)
+	}
+	return strings.Join(lines, "\n")
+}
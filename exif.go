@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"strings"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+type exifInfo struct {
+	camera      string
+	lens        string
+	exposure    string
+	date        string
+	gps         string
+	orientation int
+}
+
+type exifEntry struct {
+	path    string
+	modTime int64
+	info    *exifInfo
+}
+
+// Metadata of the last photo. Both the preview and the status bar show it,
+// and decoding reads the file up to its EXIF block.
+var exifCache exifEntry
+
+// readExif returns photo metadata of JPEG and TIFF images, or nil if there
+// is none.
+func readExif(path string) *exifInfo {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	if exifCache.path == path && exifCache.modTime == stat.ModTime().UnixNano() {
+		return exifCache.info
+	}
+	exifCache = exifEntry{path: path, modTime: stat.ModTime().UnixNano()}
+
+	if format := imageFormat(path); format != "jpeg" && format != "tiff" {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	x, err := exif.Decode(file)
+	if err != nil {
+		return nil
+	}
+
+	info := &exifInfo{orientation: 1}
+	str := func(name exif.FieldName) string {
+		tag, err := x.Get(name)
+		if err != nil {
+			return ""
+		}
+		s, err := tag.StringVal()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(strings.Trim(s, "\x00"))
+	}
+	rat := func(name exif.FieldName) (int64, int64, bool) {
+		tag, err := x.Get(name)
+		if err != nil {
+			return 0, 0, false
+		}
+		num, den, err := tag.Rat2(0)
+		return num, den, err == nil && den != 0
+	}
+
+	maker, model := str(exif.Make), str(exif.Model)
+	if strings.HasPrefix(model, maker) {
+		// Many cameras repeat the maker in the model.
+		maker = ""
+	}
+	info.camera = strings.TrimSpace(maker + " " + model)
+	info.lens = str(exif.LensModel)
+
+	var exposure []string
+	if num, den, ok := rat(exif.ExposureTime); ok {
+		if num < den && num > 0 {
+			exposure = append(exposure, fmt.Sprintf("1/%.0fs", float64(den)/float64(num)))
+		} else {
+			exposure = append(exposure, fmt.Sprintf("%gs", float64(num)/float64(den)))
+		}
+	}
+	if num, den, ok := rat(exif.FNumber); ok {
+		exposure = append(exposure, fmt.Sprintf("f/%.1f", float64(num)/float64(den)))
+	}
+	if tag, err := x.Get(exif.ISOSpeedRatings); err == nil {
+		if iso, err := tag.Int(0); err == nil {
+			exposure = append(exposure, fmt.Sprintf("ISO %d", iso))
+		}
+	}
+	if num, den, ok := rat(exif.FocalLength); ok {
+		exposure = append(exposure, fmt.Sprintf("%.0fmm", float64(num)/float64(den)))
+	}
+	info.exposure = strings.Join(exposure, " ")
+
+	if date, err := x.DateTime(); err == nil {
+		info.date = date.Format("2006-01-02 15:04:05")
+	}
+	if lat, long, err := x.LatLong(); err == nil {
+		info.gps = fmt.Sprintf("%.6f, %.6f", lat, long)
+	}
+	if tag, err := x.Get(exif.Orientation); err == nil {
+		if o, err := tag.Int(0); err == nil && o >= 1 && o <= 8 {
+			info.orientation = o
+		}
+	}
+
+	exifCache.info = info
+	return info
+}
+
+var orientationNames = []string{
+	1: "Normal",
+	2: "Mirrored",
+	3: "Rotated 180°",
+	4: "Mirrored vertically",
+	5: "Mirrored, rotated 270°",
+	6: "Rotated 90°",
+	7: "Mirrored, rotated 90°",
+	8: "Rotated 270°",
+}
+
+// lines returns metadata block shown under the image in preview.
+func (e *exifInfo) lines() []string {
+	var lines []string
+	add := func(name, value string) {
+		if value != "" {
+			lines = append(lines, bold.Render(fmt.Sprintf("%-12s", name))+value)
+		}
+	}
+	add("Camera", e.camera)
+	add("Lens", e.lens)
+	add("Exposure", e.exposure)
+	add("Date", e.date)
+	add("GPS", e.gps)
+	if e.orientation != 1 {
+		add("Orientation", orientationNames[e.orientation])
+	}
+	return lines
+}
+
+// orient transforms image according to EXIF orientation tag, so it is drawn
+// the way camera was held.
+func orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	// Orientations 5-8 swap width and height.
+	transpose := orientation >= 5
+	dw, dh := w, h
+	if transpose {
+		dw, dh = h, w
+	}
+	out := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			out.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return out
+}
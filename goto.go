@@ -0,0 +1,262 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/sahilm/fuzzy"
+)
+
+const maxSuggestions = 8
+
+// expandPath resolves a typed path: environment variables and ~ are
+// expanded, and relative paths are relative to cwd.
+func expandPath(input, cwd string) string {
+	p := os.ExpandEnv(input)
+	if p == "~" || strings.HasPrefix(p, "~/") || strings.HasPrefix(p, "~"+fileSeparator) {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = home + p[1:]
+		}
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(cwd, p)
+	}
+	return filepath.Clean(p)
+}
+
+// splitInput splits typed path into the directory part, with the trailing
+// separator, and the name being typed.
+func splitInput(input string) (string, string) {
+	i := strings.LastIndexAny(input, "/"+fileSeparator)
+	return input[:i+1], input[i+1:]
+}
+
+func commonPrefix(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	prefix := []rune(names[0])
+	for _, name := range names[1:] {
+		for !strings.HasPrefix(name, string(prefix)) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return string(prefix)
+}
+
+// pathPrompt asks for a path to go to, suggesting directories.
+type pathPrompt struct {
+	input       string
+	dir         string        // Directory, entries of which are listed.
+	entries     []os.DirEntry // Entries of dir.
+	suggestions []string      // Directories matching the typed name.
+	selected    int           // Selected suggestion, or -1.
+	message     string
+}
+
+func (m *model) openPathPrompt() {
+	m.prompt = &pathPrompt{selected: -1}
+	m.prompt.suggest(m.path, m.hideHidden)
+}
+
+// suggest updates suggestions for the typed path.
+func (p *pathPrompt) suggest(cwd string, hideHidden bool) {
+	dirInput, name := splitInput(p.input)
+	dir := expandPath(dirInput, cwd)
+	if dir != p.dir {
+		p.dir = dir
+		p.entries, _ = os.ReadDir(dir)
+	}
+	var dirs []string
+	for _, entry := range p.entries {
+		if hideHidden && strings.HasPrefix(entry.Name(), ".") && !strings.HasPrefix(name, ".") {
+			continue
+		}
+		if isDir(filepath.Join(dir, entry.Name()), entry) {
+			dirs = append(dirs, entry.Name())
+		}
+	}
+	p.suggestions = p.suggestions[:0]
+	if name == "" {
+		p.suggestions = append(p.suggestions, dirs...)
+	} else {
+		for _, match := range fuzzy.Find(name, dirs) {
+			p.suggestions = append(p.suggestions, match.Str)
+		}
+	}
+	if len(p.suggestions) > maxSuggestions {
+		p.suggestions = p.suggestions[:maxSuggestions]
+	}
+	p.selected = -1
+}
+
+// isDir reports whether entry is a directory, or a symlink to one.
+func isDir(path string, entry os.DirEntry) bool {
+	if entry.IsDir() {
+		return true
+	}
+	if entry.Type()&os.ModeSymlink != 0 {
+		fi, err := os.Stat(path)
+		return err == nil && fi.IsDir()
+	}
+	return false
+}
+
+// complete extends the typed name to the longest common prefix of matching
+// files. Returns false if there is nothing to add.
+func (p *pathPrompt) complete() bool {
+	dirInput, name := splitInput(p.input)
+	var names []string
+	var match os.DirEntry
+	for _, entry := range p.entries {
+		if strings.HasPrefix(entry.Name(), name) {
+			names = append(names, entry.Name())
+			match = entry
+		}
+	}
+	prefix := commonPrefix(names)
+	if len(names) == 1 && isDir(filepath.Join(p.dir, match.Name()), match) {
+		prefix += fileSeparator
+	}
+	if len(prefix) <= len(name) {
+		return false
+	}
+	p.input = dirInput + prefix
+	return true
+}
+
+// accept puts the selected, or the first, suggestion into the input.
+func (p *pathPrompt) accept() bool {
+	if len(p.suggestions) == 0 {
+		return false
+	}
+	dirInput, _ := splitInput(p.input)
+	p.input = dirInput + p.suggestions[max(0, p.selected)] + fileSeparator
+	return true
+}
+
+func (m *model) updatePathPrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := m.prompt
+	p.message = ""
+	switch {
+	case key.Matches(msg, keyForceQuit):
+		m.quitting = true
+		m.exitCode = 2
+		m.dontDoPendingDeletions()
+		return m, tea.Quit
+
+	case key.Matches(msg, keyQuit):
+		m.prompt = nil
+		return m, nil
+
+	case key.Matches(msg, keyUp):
+		if p.selected < 0 {
+			p.selected = len(p.suggestions) - 1
+		} else {
+			p.selected--
+		}
+		return m, nil
+
+	case key.Matches(msg, keyDown):
+		p.selected++
+		if p.selected >= len(p.suggestions) {
+			p.selected = -1
+		}
+		return m, nil
+
+	case key.Matches(msg, keyPathComplete):
+		if p.selected >= 0 || !p.complete() {
+			p.accept()
+		}
+
+	case key.Matches(msg, keyOpen):
+		if p.selected >= 0 {
+			p.accept()
+		}
+		if err := m.goTo(expandPath(p.input, m.path)); err != nil {
+			p.message = err.Error()
+			return m, nil
+		}
+		m.prompt = nil
+		return m, nil
+
+	case key.Matches(msg, keyBack):
+		if len(p.input) > 0 {
+			runes := []rune(p.input)
+			p.input = string(runes[:len(runes)-1])
+		}
+
+	case msg.Type == tea.KeyCtrlU:
+		p.input = ""
+
+	case msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace:
+		p.input += string(msg.Runes)
+
+	default:
+		return m, nil
+	}
+	p.suggest(m.path, m.hideHidden)
+	return m, nil
+}
+
+// goTo changes the current directory to the directory at path, or to the
+// directory of the file with the cursor on it.
+func (m *model) goTo(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	m.search = ""
+	m.searchMode = false
+	m.c, m.r, m.offset = 0, 0, 0
+	if fi.IsDir() {
+		m.path = path
+		if p, ok := m.positions[m.path]; ok {
+			m.c, m.r, m.offset = p.c, p.r, p.offset
+		}
+	} else {
+		m.path = filepath.Dir(path)
+		m.prevName = filepath.Base(path)
+		m.findPrevName = true
+	}
+	m.list()
+	return nil
+}
+
+func (m *model) pathPromptView() string {
+	p := m.prompt
+	width := m.termWidth - 4 // Border and padding.
+
+	lines := []string{
+		bold.Render("Go to"),
+		truncateLeft(p.input, width-1) + cursor.Render(" "), // Keep the end of the path visible.
+	}
+	for i, name := range p.suggestions {
+		name = ansi.Truncate(name+fileSeparator, width, "…")
+		if i == p.selected {
+			name = cursor.Render(name)
+		} else {
+			name = lineNumber.Render(name)
+		}
+		lines = append(lines, name)
+	}
+	lines = append(lines, "")
+	if p.message != "" {
+		lines = append(lines, ansi.Truncate(bar.Render(p.message), width, "…"))
+	} else {
+		lines = append(lines, ansi.Truncate(lineNumber.Render("tab: complete, ↑↓: choose, enter: go, esc: cancel"), width, "…"))
+	}
+
+	box := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(mainColor).
+		Padding(0, 1).
+		Width(min(width, max(60, strlen(p.input)+1)) + 2).
+		Render(strings.Join(lines, "\n"))
+	return lipgloss.Place(m.termWidth, m.termHeight, lipgloss.Center, lipgloss.Center, box)
+}
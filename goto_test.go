@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpandPath(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Setenv("WALK_TEST_DIR", "/opt")
+	cwd := filepath.FromSlash("/tmp/cwd")
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"", cwd},
+		{"sub", filepath.Join(cwd, "sub")},
+		{"../x", filepath.FromSlash("/tmp/x")},
+		{"~", home},
+		{"~/docs/", filepath.Join(home, "docs")},
+		{"$WALK_TEST_DIR/bin", filepath.FromSlash("/opt/bin")},
+		{"${WALK_TEST_DIR}", filepath.FromSlash("/opt")},
+	}
+
+	for _, tc := range testCases {
+		result := expandPath(tc.input, cwd)
+		if result != tc.expected {
+			t.Errorf("Failed: %q: %q != %q", tc.input, result, tc.expected)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	dir, name := splitInput("~/src/wa")
+	if dir != "~/src/" || name != "wa" {
+		t.Errorf("Failed: split %q, %q", dir, name)
+	}
+	if prefix := commonPrefix([]string{"walk", "walker", "wall"}); prefix != "wal" {
+		t.Errorf("Failed: common prefix %q", prefix)
+	}
+	// "Ä" and "Ö" share the first byte.
+	if prefix := commonPrefix([]string{"Äpfel", "Öl"}); prefix != "" {
+		t.Errorf("Failed: common prefix %q", prefix)
+	}
+	if prefix := commonPrefix([]string{"Äpfel", "Äste"}); prefix != "Ä" {
+		t.Errorf("Failed: common prefix %q", prefix)
+	}
+}
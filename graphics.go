@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/nfnt/resize"
+)
+
+type graphicsProtocol int
+
+const (
+	graphicsNone graphicsProtocol = iota // Half-block characters.
+	graphicsKitty
+	graphicsITerm
+	graphicsSixel
+)
+
+var graphics = graphicsNone
+
+// Deletes all kitty images with their data, quietly.
+const kittyDelete = "\x1b_Ga=d,d=A,q=2\x1b\\"
+
+// detectGraphics guesses graphics protocol supported by the terminal from
+// environment. It can be overridden with WALK_GRAPHICS.
+func detectGraphics() graphicsProtocol {
+	switch os.Getenv("WALK_GRAPHICS") {
+	case "kitty":
+		return graphicsKitty
+	case "iterm":
+		return graphicsITerm
+	case "sixel":
+		return graphicsSixel
+	case "none":
+		return graphicsNone
+	}
+
+	// Inside tmux, escape sequences need passthrough wrapping, so fall
+	// back to half-blocks unless asked explicitly.
+	if os.Getenv("TMUX") != "" {
+		return graphicsNone
+	}
+
+	term := os.Getenv("TERM")
+	termProgram := os.Getenv("TERM_PROGRAM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || term == "xterm-kitty" || termProgram == "ghostty":
+		return graphicsKitty
+	case termProgram == "iTerm.app" || termProgram == "WezTerm" || os.Getenv("LC_TERMINAL") == "iTerm2":
+		return graphicsITerm
+	case strings.HasPrefix(term, "foot") || strings.HasPrefix(term, "mlterm") || strings.HasPrefix(term, "yaft") || strings.Contains(term, "sixel"):
+		return graphicsSixel
+	}
+	return graphicsNone
+}
+
+// drawGraphics renders image with the terminal graphics protocol, fitted into
+// width x height cells. The escape sequence is put on the first line and the
+// rest of the lines are left empty to reserve space for the image.
+func drawGraphics(img image.Image, width, height int) string {
+	cellWidth, cellHeight := cellSize()
+	bounds := img.Bounds()
+	scale := math.Min(
+		float64(width*cellWidth)/float64(bounds.Dx()),
+		float64(height*cellHeight)/float64(bounds.Dy()),
+	)
+	w := max(1, int(float64(bounds.Dx())*scale))
+	h := max(1, int(float64(bounds.Dy())*scale))
+	img = resize.Resize(uint(w), uint(h), img, resize.Lanczos3)
+	cols := int(math.Ceil(float64(w) / float64(cellWidth)))
+	rows := int(math.Ceil(float64(h) / float64(cellHeight)))
+
+	var seq string
+	switch graphics {
+	case graphicsKitty:
+		seq = kittyImage(img, cols, rows)
+	case graphicsITerm:
+		seq = itermImage(img, cols, rows)
+	case graphicsSixel:
+		seq = sixelImage(img)
+	}
+
+	// Save and restore cursor around the image, so the rest of the line
+	// is drawn where the renderer expects it.
+	return "\x1b7" + seq + "\x1b8" + strings.Repeat("\n", rows-1)
+}
+
+func encodePNG(img image.Image) string {
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, img)
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func kittyImage(img image.Image, cols, rows int) string {
+	data := encodePNG(img)
+	var out strings.Builder
+	out.WriteString(kittyDelete)
+	// Kitty requires payload to be sent in chunks of at most 4096 bytes.
+	for i := 0; i < len(data); i += 4096 {
+		chunk := data[i:min(i+4096, len(data))]
+		more := 0
+		if i+4096 < len(data) {
+			more = 1
+		}
+		if i == 0 {
+			fmt.Fprintf(&out, "\x1b_Ga=T,f=100,q=2,C=1,c=%d,r=%d,m=%d;%s\x1b\\", cols, rows, more, chunk)
+		} else {
+			fmt.Fprintf(&out, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+		}
+	}
+	return out.String()
+}
+
+func itermImage(img image.Image, cols, rows int) string {
+	data := encodePNG(img)
+	return fmt.Sprintf("\x1b]1337;File=inline=1;width=%d;height=%d;preserveAspectRatio=1:%s\a", cols, rows, data)
+}
+
+func sixelImage(img image.Image) string {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	colors := palette.WebSafe
+	paletted := image.NewPaletted(image.Rect(0, 0, w, h), colors)
+	draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), img, bounds.Min)
+
+	var out strings.Builder
+	// P2=1 keeps pixels without a color transparent.
+	fmt.Fprintf(&out, "\x1bP0;1;0q\"1;1;%d;%d", w, h)
+	for i, c := range colors {
+		r, g, b, _ := c.RGBA()
+		fmt.Fprintf(&out, "#%d;2;%d;%d;%d", i, r*100/0xffff, g*100/0xffff, b*100/0xffff)
+	}
+
+	transparent := func(x, y int) bool {
+		_, _, _, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+		return a < 0x8000
+	}
+
+	band := make([]byte, w)
+	for y := 0; y < h; y += 6 {
+		used := make(map[uint8]bool)
+		for dy := 0; dy < 6 && y+dy < h; dy++ {
+			for x := 0; x < w; x++ {
+				if !transparent(x, y+dy) {
+					used[paletted.ColorIndexAt(x, y+dy)] = true
+				}
+			}
+		}
+		for c := range used {
+			for x := 0; x < w; x++ {
+				var bits byte
+				for dy := 0; dy < 6 && y+dy < h; dy++ {
+					if paletted.ColorIndexAt(x, y+dy) == c && !transparent(x, y+dy) {
+						bits |= 1 << dy
+					}
+				}
+				band[x] = 63 + bits
+			}
+			fmt.Fprintf(&out, "#%d", c)
+			writeSixelRuns(&out, band)
+			out.WriteByte('$') // Return to start of the band for the next color.
+		}
+		out.WriteByte('-') // Next band.
+	}
+	out.WriteString("\x1b\\")
+	return out.String()
+}
+
+// writeSixelRuns writes sixel characters compressing repeated runs.
+func writeSixelRuns(out *strings.Builder, band []byte) {
+	for i := 0; i < len(band); {
+		j := i
+		for j < len(band) && band[j] == band[i] {
+			j++
+		}
+		if n := j - i; n > 3 {
+			fmt.Fprintf(out, "!%d%c", n, band[i])
+		} else {
+			out.Write(band[i:j])
+		}
+		i = j
+	}
+}
+
+// eraseGraphics remembers which image is shown now and returns escape sequence
+// to remove the previously shown one, if it has changed.
+func (m *model) eraseGraphics(shown string) string {
+	prev := m.imageShown
+	m.imageShown = shown
+	if graphics == graphicsKitty && prev != "" && prev != shown {
+		return kittyDelete
+	}
+	return ""
+}
@@ -0,0 +1,7 @@
+//go:build windows
+
+package main
+
+func cellSize() (int, int) {
+	return 10, 20
+}
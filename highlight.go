@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+const (
+	defaultDarkStyle  = "monokai"
+	defaultLightStyle = "friendly"
+)
+
+var (
+	highlightStyle     string                    // Chroma style, picked by terminal background if empty.
+	highlightFormatter string                    // Chroma formatter, picked by color profile if empty.
+	lexerOverrides     = make(map[string]string) // Lexer names per extension or glob pattern.
+	lexerPatterns      []string                  // Glob patterns of lexerOverrides, in order.
+)
+
+// parseLexers parses WALK_LEXERS, like "tmpl:go-html-template;*.conf:nginx".
+// Keys are extensions, file names or glob patterns.
+func parseLexers(s string) {
+	for _, pair := range strings.Split(s, ";") {
+		pattern, name, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || pattern == "" || name == "" {
+			continue
+		}
+		if strings.ContainsAny(pattern, "*?[") {
+			lexerPatterns = append(lexerPatterns, pattern)
+		}
+		lexerOverrides[pattern] = name
+	}
+}
+
+func chromaStyle() *chroma.Style {
+	name := highlightStyle
+	if name == "" {
+		name = defaultLightStyle
+		if darkBackground {
+			name = defaultDarkStyle
+		}
+	}
+	return styles.Get(name)
+}
+
+func chromaFormatter() chroma.Formatter {
+	name := highlightFormatter
+	if name == "" {
+		switch lipgloss.ColorProfile() {
+		case termenv.TrueColor:
+			name = "terminal16m"
+		case termenv.ANSI256:
+			name = "terminal256"
+		default:
+			name = "terminal16"
+		}
+	}
+	return formatters.Get(name)
+}
+
+// lexer returns lexer for a file, preferring user overrides.
+func lexer(filePath string) chroma.Lexer {
+	base := filepath.Base(filePath)
+	var l chroma.Lexer
+	if name, ok := lexerOverrides[base]; ok {
+		l = lexers.Get(name)
+	} else if name, ok := lexerOverrides[extension(filePath)]; ok {
+		l = lexers.Get(name)
+	}
+	if l == nil {
+		for _, pattern := range lexerPatterns {
+			if ok, _ := filepath.Match(pattern, base); ok {
+				l = lexers.Get(lexerOverrides[pattern])
+				break
+			}
+		}
+	}
+	if l == nil {
+		l = lexers.Match(base)
+	}
+	if l == nil {
+		l = lexers.Fallback
+	}
+	return chroma.Coalesce(l)
+}
+
+func highlight(filePath, content string) string {
+	if !withHighlight {
+		return content
+	}
+	iterator, err := lexer(filePath).Tokenise(nil, content)
+	if err != nil {
+		return content
+	}
+	var buf bytes.Buffer
+	if err := chromaFormatter().Format(&buf, chromaStyle(), iterator); err != nil {
+		return content
+	}
+	return buf.String()
+}
+
+// listStyles prints names of available styles and formatters for --list-styles.
+func listStyles(out io.Writer) {
+	_, _ = fmt.Fprintf(out, "Styles (WALK_STYLE):\n")
+	for _, name := range styles.Names() {
+		_, _ = fmt.Fprintf(out, "  %s\n", name)
+	}
+	_, _ = fmt.Fprintf(out, "\nFormatters (WALK_FORMATTER):\n")
+	for _, name := range formatters.Names() {
+		if strings.HasPrefix(name, "terminal") {
+			_, _ = fmt.Fprintf(out, "  %s\n", name)
+		}
+	}
+}
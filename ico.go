@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+var errInvalidICO = errors.New("invalid ico file")
+
+// maxICOSize is the largest width and height the ICO directory can describe.
+const maxICOSize = 256
+
+func le16(b []byte) int {
+	return int(binary.LittleEndian.Uint16(b))
+}
+
+func le32(b []byte) int {
+	return int(binary.LittleEndian.Uint32(b))
+}
+
+func isICO(head []byte) bool {
+	if len(head) < 22 {
+		return false
+	}
+	count := le16(head[4:6])
+	// Check reserved fields of the header and of the first entry.
+	return le16(head[0:2]) == 0 && le16(head[2:4]) == 1 && count > 0 && head[9] == 0
+}
+
+// decodeICO decodes the largest image of an ICO file. Images are stored
+// either as PNG or as a BMP without the file header, with an AND mask for
+// transparency after the pixels.
+func decodeICO(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !isICO(data) || len(data) < 6+16*le16(data[4:6]) {
+		return nil, errInvalidICO
+	}
+
+	best, bestSize := -1, -1
+	for i := 0; i < le16(data[4:6]); i++ {
+		entry := data[6+16*i:]
+		w, h := int(entry[0]), int(entry[1])
+		if w == 0 {
+			w = 256
+		}
+		if h == 0 {
+			h = 256
+		}
+		if size := w*h*64 + le16(entry[6:8]); size > bestSize {
+			best, bestSize = i, size
+		}
+	}
+	entry := data[6+16*best:]
+	size, offset := le32(entry[8:12]), le32(entry[12:16])
+	if offset+size > len(data) {
+		return nil, errInvalidICO
+	}
+	img := data[offset : offset+size]
+
+	if bytes.HasPrefix(img, []byte("\x89PNG")) {
+		return png.Decode(bytes.NewReader(img))
+	}
+	return decodeDIB(img)
+}
+
+func decodeDIB(b []byte) (image.Image, error) {
+	if len(b) < 40 {
+		return nil, errInvalidICO
+	}
+	headerSize := le32(b[0:4])
+	width := le32(b[4:8])
+	height := le32(b[8:12]) / 2 // Height includes the AND mask.
+	bpp := le16(b[14:16])
+	if le32(b[16:20]) != 0 {
+		return nil, errInvalidICO // Compressed.
+	}
+	switch bpp {
+	case 1, 4, 8, 24, 32:
+	default:
+		return nil, errInvalidICO
+	}
+	// Sizes come from the file, check them before allocating the image.
+	if width <= 0 || height <= 0 || width > maxICOSize || height > maxICOSize || headerSize < 40 || headerSize > len(b) {
+		return nil, errInvalidICO
+	}
+
+	var palette color.Palette
+	offset := headerSize
+	if bpp <= 8 {
+		colors := le32(b[32:36])
+		if colors == 0 || colors > 1<<bpp {
+			colors = 1 << bpp
+		}
+		if offset+colors*4 > len(b) {
+			return nil, errInvalidICO
+		}
+		for i := 0; i < colors; i++ {
+			c := b[offset+i*4:]
+			palette = append(palette, color.NRGBA{R: c[2], G: c[1], B: c[0], A: 0xff})
+		}
+		offset += colors * 4
+	}
+
+	stride := (width*bpp + 31) / 32 * 4
+	maskStride := (width + 31) / 32 * 4
+	maskOffset := offset + stride*height
+	if maskOffset > len(b) {
+		return nil, errInvalidICO
+	}
+	hasMask := bpp < 32 && maskOffset+maskStride*height <= len(b)
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		row := b[offset+(height-1-y)*stride:] // Rows are stored bottom-up.
+		for x := 0; x < width; x++ {
+			var c color.NRGBA
+			switch bpp {
+			case 32:
+				c = color.NRGBA{R: row[x*4+2], G: row[x*4+1], B: row[x*4], A: row[x*4+3]}
+			case 24:
+				c = color.NRGBA{R: row[x*3+2], G: row[x*3+1], B: row[x*3], A: 0xff}
+			case 8, 4, 1:
+				bit := x * bpp
+				i := int(row[bit/8]>>(8-bpp-bit%8)) & (1<<bpp - 1)
+				if i < len(palette) {
+					c = palette[i].(color.NRGBA)
+				}
+			}
+			if hasMask {
+				mask := b[maskOffset+(height-1-y)*maskStride:]
+				if mask[x/8]&(0x80>>(x%8)) != 0 {
+					c.A = 0
+				}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img, nil
+}
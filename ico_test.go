@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestDecodeDIB(t *testing.T) {
+	dib := func(width, height, bpp int) []byte {
+		b := make([]byte, 40, 64)
+		binary.LittleEndian.PutUint32(b[0:4], 40)
+		binary.LittleEndian.PutUint32(b[4:8], uint32(width))
+		binary.LittleEndian.PutUint32(b[8:12], uint32(height*2))
+		binary.LittleEndian.PutUint16(b[14:16], uint16(bpp))
+		return b
+	}
+
+	b := append(dib(1, 1, 32), 0x10, 0x20, 0x30, 0xff)
+	img, err := decodeDIB(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, g, bl, _ := img.At(0, 0).RGBA(); r>>8 != 0x30 || g>>8 != 0x20 || bl>>8 != 0x10 {
+		t.Errorf("Failed: pixel %x %x %x", r>>8, g>>8, bl>>8)
+	}
+
+	for _, tc := range []struct{ width, height, bpp int }{
+		{1 << 30, 1 << 29, 0}, // Zero stride would pass the size check.
+		{1000, 1000, 32},
+		{16, 16, 7},
+	} {
+		if _, err := decodeDIB(dib(tc.width, tc.height, tc.bpp)); err != errInvalidICO {
+			t.Errorf("Failed: %dx%d %d bpp: %v", tc.width, tc.height, tc.bpp, err)
+		}
+	}
+}
@@ -7,35 +7,242 @@ import (
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
+	"io"
 	"os"
+	"strings"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/nfnt/resize"
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
 )
 
+// Size of the longer side SVG images are rasterized to.
+const svgSize = 1024
+
+type imageFormatEntry struct {
+	path    string
+	modTime int64
+	format  string
+}
+
+// The previewed file is checked for being an image on every render, and
+// opening it may be slow, on network drives for example.
+var imageFormatCache imageFormatEntry
+
+// imageFormat detects image format by file content. Returns empty string if
+// the file is not an image.
+func imageFormat(path string) string {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	c := &imageFormatCache
+	if c.path != path || c.modTime != stat.ModTime().UnixNano() {
+		*c = imageFormatEntry{path: path, modTime: stat.ModTime().UnixNano(), format: sniffImage(path)}
+	}
+	return c.format
+}
+
+func sniffImage(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return "png"
+	case bytes.HasPrefix(head, []byte("\xff\xd8\xff")):
+		return "jpeg"
+	case bytes.HasPrefix(head, []byte("GIF87a")), bytes.HasPrefix(head, []byte("GIF89a")):
+		return "gif"
+	case len(head) >= 12 && string(head[0:4]) == "RIFF" && string(head[8:12]) == "WEBP":
+		return "webp"
+	case bytes.HasPrefix(head, []byte("II*\x00")), bytes.HasPrefix(head, []byte("MM\x00*")):
+		return "tiff"
+	case isBMP(head):
+		return "bmp"
+	case isICO(head):
+		return "ico"
+	case isSVG(head):
+		return "svg"
+	}
+	return ""
+}
+
 func isImage(path string) bool {
-	ext := extension(path)
-	return ext == "png" || ext == "jpg" || ext == "jpeg" || ext == "gif"
+	return imageFormat(path) != ""
+}
+
+func isBMP(head []byte) bool {
+	if len(head) < 18 || string(head[0:2]) != "BM" {
+		return false
+	}
+	// "BM" alone is too weak, check size of the info header as well.
+	switch le32(head[14:18]) {
+	case 12, 40, 52, 56, 108, 124:
+		return true
+	}
+	return false
 }
 
-func drawImage(path string, width, height int) (string, error) {
+func isSVG(head []byte) bool {
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	head = bytes.TrimSpace(head)
+	return bytes.HasPrefix(head, []byte("<")) && bytes.Contains(bytes.ToLower(head), []byte("<svg"))
+}
+
+func decodeImage(path string) (image.Image, string, error) {
 	file, err := os.Open(path)
 	if err != nil {
-		return "", err
+		return nil, "", err
 	}
 	defer file.Close()
 
-	img, _, err := image.Decode(file)
+	switch imageFormat(path) {
+	case "svg":
+		img, err := decodeSVG(file)
+		return img, "svg", err
+	case "ico":
+		img, err := decodeICO(file)
+		return img, "ico", err
+	}
+	return image.Decode(file)
+}
+
+func decodeSVG(r io.Reader) (image.Image, error) {
+	icon, err := oksvg.ReadIconStream(r, oksvg.WarnErrorMode)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	w, h := icon.ViewBox.W, icon.ViewBox.H
+	if w <= 0 || h <= 0 {
+		w, h = svgSize, svgSize
 	}
+	scale := svgSize / max(w, h)
+	width, height := int(w*scale), int(h*scale)
+	icon.SetTarget(0, 0, float64(width), float64(height))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	scanner := rasterx.NewScannerGV(width, height, img, img.Bounds())
+	icon.Draw(rasterx.NewDasher(width, height, scanner), 1)
+	return img, nil
+}
+
+type imageEntry struct {
+	path          string
+	modTime       int64
+	width, height int
+	content       string
+	info          string
+}
+
+// Preview of the last image, for its preview size. Decoding and scaling a
+// photo from a camera takes hundreds of milliseconds.
+var imageCache imageEntry
+
+// drawImage renders image fitted into width x height cells keeping its aspect
+// ratio. Also returns image dimensions and format for the preview header.
+func drawImage(path string, width, height int) (string, string, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return "", "", err
+	}
+	entry := imageEntry{
+		path:    path,
+		modTime: stat.ModTime().UnixNano(),
+		width:   width,
+		height:  height,
+	}
+	if imageCache.path == entry.path && imageCache.modTime == entry.modTime &&
+		imageCache.width == width && imageCache.height == height {
+		return imageCache.content, imageCache.info, nil
+	}
+
+	img, format, err := decodeImage(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Photo metadata is shown under the image.
+	var meta []string
+	orientation := 1
+	if x := readExif(path); x != nil {
+		orientation = x.orientation
+		meta = x.lines()
+	}
+	transpose := orientation >= 5 && orientation <= 8
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if transpose {
+		w, h = h, w
+	}
+	entry.info = fmt.Sprintf("%d×%d %s", w, h, strings.ToUpper(format))
+
+	imageHeight := height
+	if len(meta) > 0 {
+		imageHeight = max(1, height-len(meta)-1) // Subtract 1 for empty line.
+	}
+	if orientation > 1 {
+		// Turning every pixel of a big photo is slow, so scale it down to
+		// the preview size first.
+		cellWidth, cellHeight := cellSize()
+		maxWidth, maxHeight := width*cellWidth, imageHeight*cellHeight
+		if transpose {
+			maxWidth, maxHeight = maxHeight, maxWidth
+		}
+		img = orient(resize.Thumbnail(uint(maxWidth), uint(maxHeight), img, resize.Lanczos3), orientation)
+	}
+	entry.content = renderImage(img, width, imageHeight)
+	if len(meta) > 0 {
+		entry.content = strings.TrimSuffix(entry.content, "\n") + "\n\n" + strings.Join(meta, "\n")
+	}
+	imageCache = entry
+	return entry.content, entry.info, nil
+}
+
+// renderImage draws image with terminal graphics if available, otherwise
+// with half-blocks.
+func renderImage(img image.Image, width, height int) string {
+	if graphics != graphicsNone {
+		return drawGraphics(img, width, height)
+	}
+	return drawBlocks(img, width, height)
+}
+
+// drawBlocks draws image with "▄" half-blocks: each cell shows two pixels,
+// the upper one as background and the lower one as foreground color.
+func drawBlocks(img image.Image, width, height int) string {
+	// Half of a cell is not square, take it into account to keep the
+	// aspect ratio.
+	cellWidth, cellHeight := cellSize()
+	pixelAspect := float64(cellHeight) / 2 / float64(cellWidth)
 
-	img = resize.Resize(uint(width), uint(height)*2, img, resize.Lanczos3)
 	bounds := img.Bounds()
+	cols := width
+	rows := int(float64(cols) * float64(bounds.Dy()) / float64(bounds.Dx()) / pixelAspect)
+	if rows > height*2 {
+		rows = height * 2
+		cols = int(float64(rows) * pixelAspect * float64(bounds.Dx()) / float64(bounds.Dy()))
+	}
+	cols = max(cols, 1)
+	rows = max(rows+rows%2, 2)
+
+	img = resize.Resize(uint(cols), uint(rows), img, resize.Lanczos3)
+	bounds = img.Bounds()
 
 	var buffer bytes.Buffer
-	for y := bounds.Min.Y + 1; y < bounds.Max.Y-1; y += 2 {
-		for x := bounds.Min.X + 1; x < bounds.Max.X-1; x++ {
+	for y := bounds.Min.Y; y+1 < bounds.Max.Y; y += 2 {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
 			r1, g1, b1, a1 := img.At(x, y+1).RGBA()
 			r2, g2, b2, a2 := img.At(x, y).RGBA()
 
@@ -57,5 +264,5 @@ func drawImage(path string, width, height int) (string, error) {
 		}
 		buffer.WriteString("\n")
 	}
-	return buffer.String(), nil
+	return buffer.String()
 }
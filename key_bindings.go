@@ -36,4 +36,52 @@ var (
 	keyYank      = key.NewBinding(key.WithKeys("y"))
 	keyHidden    = key.NewBinding(key.WithKeys("."))
 	keyHelp      = key.NewBinding(key.WithKeys("?"))
+
+	keyRender       = key.NewBinding(key.WithKeys("r"))
+	keyPreviewFocus = key.NewBinding(key.WithKeys("tab"))
+	keyLineNumbers  = key.NewBinding(key.WithKeys("#"))
+	keyNextMatch    = key.NewBinding(key.WithKeys("n"))
+	keyPrevMatch    = key.NewBinding(key.WithKeys("N"))
+
+	keyView        = key.NewBinding(key.WithKeys("v"))
+	keyPagerWrap   = key.NewBinding(key.WithKeys("w"))
+	keyPagerGoto   = key.NewBinding(key.WithKeys(":"))
+	keyPagerFollow = key.NewBinding(key.WithKeys("F"))
+
+	keyFold   = key.NewBinding(key.WithKeys("["))
+	keyUnfold = key.NewBinding(key.WithKeys("]"))
+
+	keyDiskUsage = key.NewBinding(key.WithKeys("U"))
+	keyDuRescan  = key.NewBinding(key.WithKeys("r"))
+
+	keyDupes        = key.NewBinding(key.WithKeys("D"))
+	keyDupesMarkAll = key.NewBinding(key.WithKeys("a"))
+
+	keySelect       = key.NewBinding(key.WithKeys("m"))
+	keyChecksum     = key.NewBinding(key.WithKeys("c"))
+	keyChecksumNext = key.NewBinding(key.WithKeys("tab"))
+	keyVerify       = key.NewBinding(key.WithKeys("C"))
+
+	keyChmod          = key.NewBinding(key.WithKeys("p"))
+	keyChmodToggle    = key.NewBinding(key.WithKeys(" ", "x"))
+	keyChmodRule      = key.NewBinding(key.WithKeys("tab"))
+	keyChmodRecursive = key.NewBinding(key.WithKeys("R"))
+
+	keyFollow   = key.NewBinding(key.WithKeys("f"))
+	keyLink     = key.NewBinding(key.WithKeys("L"))
+	keyLinkKind = key.NewBinding(key.WithKeys("tab"))
+
+	keyDiff      = key.NewBinding(key.WithKeys("="))
+	keyDiffSplit = key.NewBinding(key.WithKeys("s"))
+	keyDiffFull  = key.NewBinding(key.WithKeys("f"))
+	keyNextHunk  = key.NewBinding(key.WithKeys("}"))
+	keyPrevHunk  = key.NewBinding(key.WithKeys("{"))
+
+	keyBreadcrumbs  = key.NewBinding(key.WithKeys("b"))
+	keyGoTo         = key.NewBinding(key.WithKeys("o"))
+	keyPathComplete = key.NewBinding(key.WithKeys("tab"))
+
+	keySort        = key.NewBinding(key.WithKeys("S"))
+	keyPalette     = key.NewBinding(key.WithKeys(":"))
+	keyPaletteFill = key.NewBinding(key.WithKeys("tab"))
 )
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// linkTarget is where a symlink in the listing points to.
+type linkTarget struct {
+	target string
+	broken bool // Target is missing.
+}
+
+// linkTargets are targets of symlinks, by their names.
+type linkTargets map[string]linkTarget
+
+// readLinks resolves symlinks among files of dir.
+func readLinks(dir string, files []os.DirEntry) linkTargets {
+	links := make(linkTargets)
+	for _, file := range files {
+		if file.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		p := filepath.Join(dir, file.Name())
+		target, err := os.Readlink(p)
+		if err != nil {
+			continue
+		}
+		_, err = os.Stat(p)
+		links[file.Name()] = linkTarget{target: target, broken: err != nil}
+	}
+	return links
+}
+
+// followLink returns the real path of the symlink target. For broken
+// links, the target as written in the link is returned.
+func followLink(path string) (string, error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		return "", errors.New(filepath.Base(path) + " is not a symlink")
+	}
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		return filepath.Abs(target)
+	}
+	target, err := os.Readlink(path)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+	return target, nil
+}
+
+// link is a prompt for a directory to create links to files in.
+type link struct {
+	files   []string
+	dir     string // Destination directory as typed.
+	hard    bool
+	message string
+}
+
+// openLink asks where to create links to selected files, or to the
+// current file.
+func (m *model) openLink() {
+	var files []string
+	for path := range m.selected {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+	if len(files) == 0 {
+		if filePath, ok := m.filePath(); ok {
+			files = append(files, filePath)
+		}
+	}
+	dir := m.path
+	if !strings.HasSuffix(dir, fileSeparator) {
+		dir += fileSeparator
+	}
+	m.link = &link{files: files, dir: dir}
+	if len(files) == 0 {
+		m.link.message = "No files"
+	}
+}
+
+func (m *model) updateLink(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	l := m.link
+	l.message = ""
+	switch {
+	case key.Matches(msg, keyForceQuit):
+		m.quitting = true
+		m.exitCode = 2
+		m.dontDoPendingDeletions()
+		return m, tea.Quit
+
+	case key.Matches(msg, keyQuit):
+		m.link = nil
+
+	case len(l.files) == 0:
+		m.link = nil
+
+	case key.Matches(msg, keyLinkKind):
+		l.hard = !l.hard
+
+	case key.Matches(msg, keyBack):
+		if len(l.dir) > 0 {
+			runes := []rune(l.dir)
+			l.dir = string(runes[:len(runes)-1])
+		}
+
+	case msg.Type == tea.KeyCtrlU:
+		l.dir = ""
+
+	case key.Matches(msg, keyOpen):
+		created, errs := l.create(m.path)
+		if len(errs) > 0 {
+			l.message = fmt.Sprintf("Linked %s, %s: %v", plural(created, "file"), plural(len(errs), "error"), errs[0])
+			return m, nil
+		}
+		m.link = nil
+		m.selected = make(map[string]bool)
+		m.message = fmt.Sprintf("Linked %s into %s", plural(created, "file"), l.dir)
+		m.list()
+		m.updateOffset()
+
+	case msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace:
+		l.dir += string(msg.Runes)
+	}
+	return m, nil
+}
+
+// create makes links in the destination directory, which is relative to
+// the current directory cwd. Symlinks point to absolute paths.
+func (l *link) create(cwd string) (int, []error) {
+	dir := expandPath(l.dir, cwd)
+	if fi, err := os.Stat(dir); err != nil {
+		return 0, []error{err}
+	} else if !fi.IsDir() {
+		return 0, []error{errors.New(dir + " is not a directory")}
+	}
+
+	created := 0
+	var errs []error
+	for _, file := range l.files {
+		target := filepath.Join(dir, filepath.Base(file))
+		var err error
+		if l.hard {
+			err = os.Link(file, target)
+		} else {
+			err = os.Symlink(file, target)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			created++
+		}
+	}
+	return created, errs
+}
+
+func (m *model) linkView() string {
+	l := m.link
+	width := m.termWidth - 4 // Border and padding.
+
+	kind := "Symlink "
+	if l.hard {
+		kind = "Hard link "
+	}
+	if len(l.files) == 1 {
+		kind += filepath.Base(l.files[0])
+	} else {
+		kind += plural(len(l.files), "file")
+	}
+	lines := []string{
+		ansi.Truncate(bold.Render(kind+" into"), width, "…"),
+		truncateLeft(l.dir, width-1) + cursor.Render(" "), // Keep the end of the path visible.
+		"",
+	}
+	if l.message != "" {
+		lines = append(lines, ansi.Truncate(bar.Render(l.message), width, "…"))
+	} else {
+		lines = append(lines, ansi.Truncate(lineNumber.Render("tab: symlink or hard link, enter: create, esc: cancel"), width, "…"))
+	}
+
+	box := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(mainColor).
+		Padding(0, 1).
+		Width(min(width, max(60, strlen(l.dir)+1)) + 2).
+		Render(strings.Join(lines, "\n"))
+	return lipgloss.Place(m.termWidth, m.termHeight, lipgloss.Center, lipgloss.Center, box)
+}
@@ -1,21 +1,18 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"io/fs"
 	"math"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
-	"runtime"
 	. "strings"
 	"time"
-	"unicode/utf8"
 
-	"github.com/alecthomas/chroma/v2/lexers"
-	"github.com/alecthomas/chroma/v2/quick"
 	"github.com/antonmedv/clipboard"
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
@@ -37,7 +34,9 @@ var (
 	dirOnly        = false
 	fuzzyByDefault = false
 	withBorder     = false
+	withMouse      = false
 	withHighlight  = true
+	darkBackground = true
 	strlen         = runewidth.StringWidth
 )
 
@@ -59,12 +58,30 @@ func main() {
 		withHighlight = false
 	}
 
+	highlightStyle = os.Getenv("WALK_STYLE")
+	highlightFormatter = os.Getenv("WALK_FORMATTER")
+	if s, ok := os.LookupEnv("WALK_LEXERS"); ok {
+		parseLexers(s)
+	}
+
+	if s, ok := os.LookupEnv("WALK_PREVIEWER"); ok {
+		parsePreviewers(s)
+	}
+	if s, ok := os.LookupEnv("WALK_PREVIEWER_TIMEOUT"); ok {
+		if d, err := time.ParseDuration(s); err == nil && d > 0 {
+			previewerTimeout = d
+		}
+	}
+
 	initStyles()
+	graphics = detectGraphics()
 
 	m := &model{
 		termWidth:  80,
 		termHeight: 60,
 		positions:  make(map[string]position),
+		rendered:   make(map[string]bool),
+		selected:   make(map[string]bool),
 	}
 
 	if statusBar, ok := os.LookupEnv("WALK_STATUS_BAR"); ok {
@@ -81,6 +98,10 @@ func main() {
 			fmt.Printf("%s\n", Version)
 			os.Exit(0)
 		}
+		if os.Args[i] == "--list-styles" {
+			listStyles(os.Stdout)
+			os.Exit(0)
+		}
 		if os.Args[i] == "--icons" {
 			showIcons = true
 			parseIcons()
@@ -106,6 +127,10 @@ func main() {
 			withBorder = true
 			continue
 		}
+		if os.Args[i] == "--mouse" {
+			withMouse = true
+			continue
+		}
 		argsWithoutFlags = append(argsWithoutFlags, os.Args[i])
 	}
 
@@ -118,6 +143,7 @@ func main() {
 
 	output := termenv.NewOutput(os.Stderr)
 	lipgloss.SetColorProfile(output.ColorProfile())
+	darkBackground = output.HasDarkBackground()
 
 	m.path = startPath
 	m.list()
@@ -125,6 +151,10 @@ func main() {
 	opts := []tea.ProgramOption{
 		tea.WithOutput(os.Stderr),
 	}
+	if withMouse {
+		// Capturing the mouse stops the terminal from selecting text.
+		opts = append(opts, tea.WithMouseCellMotion())
+	}
 	if m.previewMode {
 		opts = append(opts, tea.WithAltScreen())
 	}
@@ -146,6 +176,7 @@ func main() {
 type model struct {
 	path                  string              // Current dir path we are looking at.
 	files                 []fs.DirEntry       // Files we are looking at.
+	links                 linkTargets         // Symlinks among files, by name.
 	err                   error               // Error while listing files.
 	c, r                  int                 // Selector position in columns and rows.
 	columns, rows         int                 // Displayed amount of rows and columns.
@@ -161,6 +192,45 @@ type model struct {
 	exitCode              int                 // Exit code.
 	previewMode           bool                // Whether preview is active.
 	previewContent        string              // Content of preview.
+	previewInfo           string              // Information about previewed file, shown next to its name.
+	rendered              map[string]bool     // Whether to render preview instead of source, per extension.
+	previewFocus          bool                // Whether preview pane has focus and receives keys.
+	previewPath           string              // Path of the previewed file, to reset scroll on change.
+	previewLines          int                 // Number of lines in preview content.
+	previewOffset         int                 // Preview vertical scroll position.
+	previewOffsetX        int                 // Preview horizontal scroll position.
+	previewSearch         string              // Search inside preview.
+	previewSearchMode     bool                // Whether preview search is being typed.
+	previewMatches        []int               // Line indexes of preview search matches.
+	previewMatch          int                 // Index of current match in previewMatches.
+	lineNumbers           bool                // Show line numbers in preview.
+	previewFold           int                 // Level at which structured preview tree is folded, zero for none.
+	previewDepth          int                 // Depth of structured preview tree.
+	pager                 *pager              // Built-in file viewer, if open.
+	du                    *diskUsage          // Disk usage mode, if open.
+	dupes                 *dupes              // Search for duplicate files, and their list.
+	selected              map[string]bool     // Paths of selected files.
+	checksums             *checksums          // Checksums overlay, if open.
+	chmod                 *chmod              // Permissions editor, if open.
+	link                  *link               // Prompt for creating links, if open.
+	message               string              // Result of the last action, shown in status bar.
+	diff                  *diff               // Diff view, if open.
+	diffLeft              string              // File picked to compare with another one.
+	layout                layout              // Positions of things on screen, for mouse.
+	lastClick             mouseClick          // Last click, to detect double clicks.
+	breadcrumbsFocus      bool                // Location bar has focus.
+	crumb                 int                 // Focused directory in location bar.
+	prompt                *pathPrompt         // Go to prompt, if open.
+	palette               *commandPalette     // Command palette, if open.
+	sortMode              string              // Sort order of files: name, size, time or ext.
+	previewImage          string              // Path of image drawn in preview with terminal graphics.
+	imageShown            string              // Path of image drawn with terminal graphics on screen.
+	animation             *animation          // Animated GIF playing in preview.
+	previewerRunning      previewerKey        // Previewer command being waited for.
+	previewerCancel       context.CancelFunc  // Stops the previewer being waited for.
+	dirStatsPath          string              // Directory whose recursive size is being computed.
+	dirStatsCancel        context.CancelFunc  // Stops computing recursive size.
+	dirStatsId            int                 // Id of the last started walk.
 	deleteCurrentFile     bool                // Whether to delete current file.
 	toBeDeleted           []toDelete          // Map of files to be deleted.
 	yankedFilePath        string              // Show yank info
@@ -190,6 +260,11 @@ func (m *model) Init() tea.Cmd {
 }
 
 func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	_, cmd := m.update(msg)
+	return m, tea.Batch(cmd, m.previewCmd())
+}
+
+func (m *model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.termWidth = msg.Width
@@ -210,15 +285,62 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.r = 0
 		return m, nil
 
+	case tea.MouseMsg:
+		return m.updateMouse(msg)
+
 	case tea.KeyMsg:
+		if m.pager != nil {
+			return m.updatePager(msg)
+		}
+
+		if m.du != nil {
+			return m.updateDiskUsage(msg)
+		}
+
+		if m.diff != nil {
+			return m.updateDiff(msg)
+		}
+
+		if m.checksums != nil {
+			return m.updateChecksums(msg)
+		}
+
+		if m.chmod != nil {
+			return m.updateChmod(msg)
+		}
+
+		if m.link != nil {
+			return m.updateLink(msg)
+		}
+
+		if m.prompt != nil {
+			return m.updatePathPrompt(msg)
+		}
+
+		if m.palette != nil {
+			return m.updatePalette(msg)
+		}
+
+		if m.dupes != nil && m.dupes.open {
+			if m.previewFocus {
+				return m.updatePreviewFocus(msg)
+			}
+			return m.updateDupes(msg)
+		}
+
 		// Make undo work even if we are in fuzzy mode.
-		if key.Matches(msg, keyUndo) && len(m.toBeDeleted) > 0 {
-			m.toBeDeleted = m.toBeDeleted[:len(m.toBeDeleted)-1]
-			m.list()
-			m.previewContent = ""
+		if key.Matches(msg, keyUndo) && m.undoDelete() {
 			return m, nil
 		}
 
+		if m.breadcrumbsFocus {
+			return m.updateBreadcrumbs(msg)
+		}
+
+		if m.previewFocus {
+			return m.updatePreviewFocus(msg)
+		}
+
 		if fuzzyByDefault {
 			if key.Matches(msg, keyBack) {
 				if len(m.search) > 0 {
@@ -252,169 +374,93 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
-		switch {
-		case key.Matches(msg, keyForceQuit):
-			m.quitting = true
-			m.exitCode = 2
-			m.dontDoPendingDeletions()
-			return m, tea.Quit
-
-		case key.Matches(msg, keyQuit, keyQuitQ):
-			m.quitting = true
-			m.exitCode = 0
-			m.performPendingDeletions()
-			return m, tea.Quit
-
-		case key.Matches(msg, keyOpen):
-			m.search = ""
-			m.searchMode = false
-			filePath, ok := m.filePath()
-			if !ok {
-				return m, nil
-			}
-			if fi := fileInfo(filePath); fi.IsDir() {
-				// Enter subdirectory.
-				m.path = filePath
-				if p, ok := m.positions[m.path]; ok {
-					m.c = p.c
-					m.r = p.r
-					m.offset = p.offset
-				} else {
-					m.c = 0
-					m.r = 0
-					m.offset = 0
-				}
-				m.list()
-			} else {
-				// Open file. This will block until complete.
-				return m, m.open()
-			}
+		return m.handleKey(msg)
 
-		case key.Matches(msg, keyBack):
-			m.search = ""
-			m.searchMode = false
-			m.prevName = filepath.Base(m.path)
-			m.path = filepath.Join(m.path, "..")
-			if p, ok := m.positions[m.path]; ok {
-				m.c = p.c
-				m.r = p.r
-				m.offset = p.offset
-			} else {
-				m.findPrevName = true
+	case animationLoadedMsg:
+		if m.animation != nil && m.animation.id == msg.id {
+			m.animation.gif = msg.gif
+			if m.animation.playing() {
+				return m, m.animation.tick()
 			}
-			m.list()
-			return m, nil
-
-		case key.Matches(msg, keyUp):
-			m.moveUp()
-
-		case key.Matches(msg, keyTop, keyPageUp, keyVimTop):
-			m.moveTop()
-
-		case key.Matches(msg, keyBottom, keyPageDown, keyVimBottom):
-			m.moveBottom()
-
-		case key.Matches(msg, keyLeftmost):
-			m.moveLeftmost()
-
-		case key.Matches(msg, keyRightmost):
-			m.moveRightmost()
-
-		case key.Matches(msg, keyHome):
-			m.moveStart()
+		}
 
-		case key.Matches(msg, keyEnd):
-			m.moveEnd()
+	case animationTickMsg:
+		if m.animation != nil && m.animation.id == int(msg) {
+			m.animation.next()
+			return m, m.animation.tick()
+		}
 
-		case key.Matches(msg, keyVimUp):
-			m.moveUp()
+	case previewerMsg:
+		if msg.err == context.Canceled {
+			break // Another file was selected.
+		}
+		previewerCache = msg
+		if m.previewerRunning == msg.key {
+			m.previewerRunning = previewerKey{}
+			m.previewerCancel = nil
+		}
 
-		case key.Matches(msg, keyDown):
-			m.moveDown()
+	case archiveMsg:
+		// Another file may be previewed by now.
+		if archiveCache.path == msg.path && archiveCache.modTime == msg.modTime {
+			archiveCache = archiveCacheEntry(msg)
+		}
 
-		case key.Matches(msg, keyVimDown):
-			m.moveDown()
+	case dirStatsMsg:
+		if msg.err == nil {
+			dirStatsCache[msg.path] = msg.dirStats
+		}
+		// A cancelled walk of the same directory may finish after a new one
+		// has started.
+		if m.dirStatsId == msg.id {
+			m.dirStatsPath = ""
+			m.dirStatsCancel = nil
+		}
 
-		case key.Matches(msg, keyLeft):
-			m.moveLeft()
+	case duTickMsg:
+		if m.du != nil && m.du.scan != nil && m.du.scanId == int(msg) {
+			return m, m.du.tick()
+		}
 
-		case key.Matches(msg, keyVimLeft):
-			m.moveLeft()
+	case duDoneMsg:
+		m.diskUsageDone(msg)
 
-		case key.Matches(msg, keyRight):
-			m.moveRight()
+	case checksumMsg:
+		return m, m.checksumDone(msg)
 
-		case key.Matches(msg, keyVimRight):
-			m.moveRight()
+	case diffMsg:
+		m.diffDone(msg)
+		return m, nil
 
-		case key.Matches(msg, keySearch):
-			m.searchMode = true
-			m.searchId++
-			m.search = ""
+	case chmodMsg:
+		m.chmodDone(msg)
+		return m, nil
 
-		case key.Matches(msg, keyPreview):
-			m.previewMode = !m.previewMode
-			// Reset position history as c&r changes.
-			m.positions = make(map[string]position)
-			// Keep cursor at same place.
-			fileName, ok := m.currentFileName()
-			if !ok {
-				return m, nil
-			}
-			m.prevName = fileName
-			m.findPrevName = true
+	case dupesTickMsg:
+		if m.dupes != nil && m.dupes.id == int(msg) && !m.dupes.found {
+			return m, m.dupes.tick()
+		}
 
-			if m.previewMode {
-				return m, tea.EnterAltScreen
+	case dupesDoneMsg:
+		if m.dupes != nil && m.dupes.id == msg.id {
+			if msg.err != nil {
+				m.dupes = nil
 			} else {
-				m.previewContent = ""
-				return m, tea.ExitAltScreen
+				m.dupes.groups = msg.groups
+				m.dupes.found = true
 			}
+		}
 
-		case key.Matches(msg, keyDelete, keyFnDelete):
-			filePathToDelete, ok := m.filePath()
-			if ok {
-				if m.deleteCurrentFile {
-					m.deleteCurrentFile = false
-					m.toBeDeleted = append(m.toBeDeleted, toDelete{
-						path: filePathToDelete,
-						at:   time.Now().Add(6 * time.Second),
-					})
-					m.list()
-					m.previewContent = ""
-					return m, tea.Tick(time.Second, func(time.Time) tea.Msg {
-						return toBeDeletedMsg(0)
-					})
-				} else {
-					m.deleteCurrentFile = true
-				}
-			}
-			return m, nil
-
-		case key.Matches(msg, keyYank):
-			filePath, ok := m.filePath()
-			if ok {
-				clipboard.WriteAll(filePath)
-				m.yankedFilePath = filePath
-				m.updateOffset()
-			}
-			return m, nil
-
-		case key.Matches(msg, keyHelp):
-			m.showHelp = !m.showHelp
-			return m, nil
-
-		case key.Matches(msg, keyHidden):
-			m.hideHidden = !m.hideHidden
-			m.list()
-
-		} // End of switch statement for key presses.
+	case pagerTickMsg:
+		if m.pager != nil && m.pager.follow && m.pager.followId == int(msg) {
+			m.pager.reload()
+			return m, m.pager.tick()
+		}
 
-		m.deleteCurrentFile = false
-		m.showHelp = false
-		m.yankedFilePath = ""
-		m.updateOffset()
-		m.saveCursorPosition()
+	case pagerSearchMsg:
+		if m.pager != nil && m.pager.findDir != 0 && m.pager.findId == int(msg) {
+			return m, m.pager.continueSearch()
+		}
 
 	case clearSearchMsg:
 		if m.searchId == int(msg) {
@@ -429,6 +475,8 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				toBeDeleted = append(toBeDeleted, td)
 			} else {
 				remove(td.path)
+				duForget(td.path)
+				m.dupesForget(td.path)
 			}
 		}
 		m.toBeDeleted = toBeDeleted
@@ -459,9 +507,332 @@ func (m *model) updateSearch(msg tea.KeyMsg) {
 	m.saveCursorPosition()
 }
 
+// handleKey handles key presses in the main view, after search and other
+// modes had a chance to handle them.
+func (m *model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keyForceQuit):
+		m.quitting = true
+		m.exitCode = 2
+		m.dontDoPendingDeletions()
+		return m, tea.Quit
+
+	case key.Matches(msg, keyQuit, keyQuitQ):
+		m.quitting = true
+		m.exitCode = 0
+		m.performPendingDeletions()
+		return m, tea.Quit
+
+	case key.Matches(msg, keyOpen):
+		m.search = ""
+		m.searchMode = false
+		filePath, ok := m.filePath()
+		if !ok {
+			return m, nil
+		}
+		fi, err := fileInfo(filePath)
+		if err != nil {
+			// File is gone, refresh the listing.
+			m.list()
+			return m, nil
+		}
+		if fi.IsDir() {
+			// Enter subdirectory.
+			m.path = filePath
+			if p, ok := m.positions[m.path]; ok {
+				m.c = p.c
+				m.r = p.r
+				m.offset = p.offset
+			} else {
+				m.c = 0
+				m.r = 0
+				m.offset = 0
+			}
+			m.list()
+		} else {
+			// Open file. This will block until complete.
+			return m, m.open()
+		}
+
+	case key.Matches(msg, keyBack):
+		m.search = ""
+		m.searchMode = false
+		m.prevName = filepath.Base(m.path)
+		m.path = filepath.Join(m.path, "..")
+		if p, ok := m.positions[m.path]; ok {
+			m.c = p.c
+			m.r = p.r
+			m.offset = p.offset
+		} else {
+			m.findPrevName = true
+		}
+		m.list()
+		return m, nil
+
+	case key.Matches(msg, keyUp):
+		m.moveUp()
+
+	case key.Matches(msg, keyTop, keyPageUp, keyVimTop):
+		m.moveTop()
+
+	case key.Matches(msg, keyBottom, keyPageDown, keyVimBottom):
+		m.moveBottom()
+
+	case key.Matches(msg, keyLeftmost):
+		m.moveLeftmost()
+
+	case key.Matches(msg, keyRightmost):
+		m.moveRightmost()
+
+	case key.Matches(msg, keyHome):
+		m.moveStart()
+
+	case key.Matches(msg, keyEnd):
+		m.moveEnd()
+
+	case key.Matches(msg, keyVimUp):
+		m.moveUp()
+
+	case key.Matches(msg, keyDown):
+		m.moveDown()
+
+	case key.Matches(msg, keyVimDown):
+		m.moveDown()
+
+	case key.Matches(msg, keyLeft):
+		m.moveLeft()
+
+	case key.Matches(msg, keyVimLeft):
+		m.moveLeft()
+
+	case key.Matches(msg, keyRight):
+		m.moveRight()
+
+	case key.Matches(msg, keyVimRight):
+		m.moveRight()
+
+	case key.Matches(msg, keySearch):
+		m.searchMode = true
+		m.searchId++
+		m.search = ""
+
+	case key.Matches(msg, keyPreview):
+		m.previewMode = !m.previewMode
+		// Reset position history as c&r changes.
+		m.positions = make(map[string]position)
+		// Keep cursor at same place.
+		fileName, ok := m.currentFileName()
+		if !ok {
+			return m, nil
+		}
+		m.prevName = fileName
+		m.findPrevName = true
+
+		if m.previewMode {
+			return m, tea.EnterAltScreen
+		} else {
+			m.previewContent = ""
+			m.previewFocus = false
+			return m, tea.ExitAltScreen
+		}
+
+	case key.Matches(msg, keyPreviewFocus):
+		m.previewFocus = m.previewMode
+
+	case key.Matches(msg, keyGoTo):
+		m.openPathPrompt()
+
+	case key.Matches(msg, keyPalette):
+		m.openPalette()
+
+	case key.Matches(msg, keySort):
+		m.setSort(m.nextSort())
+		m.updateOffset()
+		return m, nil
+
+	case key.Matches(msg, keyBreadcrumbs):
+		m.breadcrumbsFocus = true
+		m.crumb = len(m.crumbs()) - 1
+
+	case key.Matches(msg, keyView):
+		filePath, ok := m.filePath()
+		if fi, err := fileInfo(filePath); ok && err == nil && !fi.IsDir() {
+			return m, m.openPager(filePath)
+		}
+
+	case key.Matches(msg, keyDiskUsage):
+		return m, m.openDiskUsage()
+
+	case key.Matches(msg, keySelect):
+		filePath, ok := m.filePath()
+		if ok {
+			if m.selected[filePath] {
+				delete(m.selected, filePath)
+			} else {
+				m.selected[filePath] = true
+			}
+			m.moveDown()
+		}
+
+	case key.Matches(msg, keyChecksum):
+		return m, m.openChecksums()
+
+	case key.Matches(msg, keyVerify):
+		return m, m.openVerify()
+
+	case key.Matches(msg, keyChmod):
+		m.openChmod()
+
+	case key.Matches(msg, keyLink):
+		m.openLink()
+
+	case key.Matches(msg, keyDiff):
+		filePath, ok := m.filePath()
+		if !ok {
+			break
+		}
+		if left, right, ok := m.diffPair(filePath); ok {
+			m.diffLeft = ""
+			return m, m.openDiff(left, right)
+		}
+		if m.diffLeft == filePath {
+			m.diffLeft = ""
+		} else {
+			m.diffLeft = filePath
+		}
+
+	case key.Matches(msg, keyFollow):
+		filePath, ok := m.filePath()
+		if !ok {
+			break
+		}
+		target, err := followLink(filePath)
+		if err != nil {
+			m.message = err.Error()
+			m.updateOffset()
+			return m, nil
+		}
+		m.search = ""
+		m.searchMode = false
+		m.path = filepath.Dir(target)
+		m.prevName = filepath.Base(target)
+		m.findPrevName = true
+		m.c, m.r, m.offset = 0, 0, 0
+		m.list()
+		return m, nil
+
+	case key.Matches(msg, keyDupes):
+		switch {
+		case m.dupes == nil || (m.dupes.found && (m.dupes.path != m.path || len(m.dupes.groups) == 0)):
+			m.stopDupes()
+			return m, m.startDupes()
+		case !m.dupes.found:
+			m.stopDupes()
+		default:
+			m.dupes.open = true
+			if !m.previewMode {
+				return m, tea.EnterAltScreen
+			}
+		}
+		return m, nil
+
+	case key.Matches(msg, keyRender):
+		filePath, ok := m.filePath()
+		if ok && (isMarkdown(filePath) || structuredFormat(filePath) != "") {
+			m.rendered[extension(filePath)] = !m.isRendered(filePath)
+		}
+
+	case key.Matches(msg, keyDelete, keyFnDelete):
+		filePathToDelete, ok := m.filePath()
+		if ok {
+			if m.deleteCurrentFile {
+				m.deleteCurrentFile = false
+				m.toBeDeleted = append(m.toBeDeleted, toDelete{
+					path: filePathToDelete,
+					at:   time.Now().Add(6 * time.Second),
+				})
+				m.list()
+				m.previewContent = ""
+				return m, tea.Tick(time.Second, func(time.Time) tea.Msg {
+					return toBeDeletedMsg(0)
+				})
+			} else {
+				m.deleteCurrentFile = true
+			}
+		}
+		return m, nil
+
+	case key.Matches(msg, keyYank):
+		filePath, ok := m.filePath()
+		if ok {
+			clipboard.WriteAll(filePath)
+			m.yankedFilePath = filePath
+			m.updateOffset()
+		}
+		return m, nil
+
+	case key.Matches(msg, keyHelp):
+		m.showHelp = !m.showHelp
+		return m, nil
+
+	case key.Matches(msg, keyHidden):
+		m.hideHidden = !m.hideHidden
+		m.list()
+
+	} // End of switch statement for key presses.
+
+	m.deleteCurrentFile = false
+	m.showHelp = false
+	m.yankedFilePath = ""
+	m.message = ""
+	if m.dupes != nil && m.dupes.found && len(m.dupes.groups) == 0 {
+		m.dupes = nil
+	}
+	m.updateOffset()
+	m.saveCursorPosition()
+	return m, nil
+}
+
 func (m *model) View() string {
+	if m.pager != nil {
+		return m.eraseGraphics("") + m.pager.view(m.termWidth, m.termHeight)
+	}
+
+	if m.du != nil {
+		return m.eraseGraphics("") + m.diskUsageView()
+	}
+
+	if m.diff != nil && m.diff.full {
+		return m.eraseGraphics("") + m.diffView(m.termWidth, m.termHeight)
+	}
+
+	if m.checksums != nil {
+		return m.eraseGraphics("") + m.checksumsView()
+	}
+
+	if m.chmod != nil {
+		return m.eraseGraphics("") + m.chmodView()
+	}
+
+	if m.link != nil {
+		return m.eraseGraphics("") + m.linkView()
+	}
+
+	if m.prompt != nil {
+		return m.eraseGraphics("") + m.pathPromptView()
+	}
+
+	if m.palette != nil {
+		return m.eraseGraphics("") + m.paletteView()
+	}
+
+	if m.dupes != nil && m.dupes.open {
+		return m.dupesView()
+	}
+
 	if m.showHelp {
 		out := &Builder{}
+		out.WriteString(m.eraseGraphics(""))
 		out.WriteString(bar.Render("help") + "\n\n")
 		usage(out, false)
 		return out.String()
@@ -474,7 +845,7 @@ func (m *model) View() string {
 	height := m.listHeight()
 
 	var names [][]string
-	names, m.rows, m.columns = wrap(m.files, width, height, func(name string, i, j int) {
+	names, m.rows, m.columns = wrap(m.files, m.links, width, height, func(name string, i, j int) {
 		if m.findPrevName && m.prevName == name {
 			m.c = i
 			m.r = j
@@ -489,8 +860,12 @@ func (m *model) View() string {
 	}
 
 	// After we have updated offset and saved cursor position, we can
-	// preview currently selected file.
-	m.preview()
+	// preview currently selected file, unless the diff takes the pane.
+	if m.diff != nil {
+		m.previewImage = ""
+	} else {
+		m.preview()
+	}
 
 	// Get output rows width before coloring.
 	outputWidth := strlen(path.Base(m.path)) // Use current dir name as default.
@@ -508,6 +883,13 @@ func (m *model) View() string {
 		outputWidth = width
 	}
 
+	m.layout.columns = m.layout.columns[:0]
+	x := 0
+	for i := 0; i < m.columns; i++ {
+		m.layout.columns = append(m.layout.columns, x)
+		x += strlen(names[i][0]) + len(separator)
+	}
+
 	// Let's add colors to file names.
 	output := make([]string, m.rows)
 	for j := 0; j < m.rows; j++ {
@@ -519,6 +901,10 @@ func (m *model) View() string {
 				} else {
 					row[i] = cursor.Render(names[i][j])
 				}
+			} else if n := i*m.rows + j; n < len(m.files) && m.selected[path.Join(m.path, m.files[n].Name())] {
+				row[i] = selected.Render(names[i][j])
+			} else if n < len(m.files) && m.links[m.files[n].Name()].broken {
+				row[i] = brokenLink.Render(names[i][j])
 			} else {
 				row[i] = names[i][j]
 			}
@@ -531,17 +917,21 @@ func (m *model) View() string {
 	}
 
 	// Preview pane.
-	fileName, _ := m.currentFileName()
-	previewPane := bar.Render(fileName) + "\n"
-	previewPane += m.previewContent
-
-	// Location bar (grey).
-	location := m.path
-	if userHomeDir, err := os.UserHomeDir(); err == nil {
-		location = Replace(m.path, userHomeDir, "~", 1)
-	}
-	if runtime.GOOS == "windows" {
-		location = ReplaceAll(Replace(location, "\\/", fileSeparator, 1), "/", fileSeparator)
+	var previewPane string
+	if m.diff != nil {
+		previewPane = m.diffView(m.termWidth-outputWidth-3, m.previewHeight()+1) // Add 1 for name bar.
+	} else {
+		fileName, _ := m.currentFileName()
+		if l, ok := m.links[fileName]; ok {
+			fileName += " -> " + l.target
+		}
+		previewContent := m.previewView(m.termWidth-outputWidth-3, m.previewHeight())
+		nameBar := bar
+		if m.previewFocus {
+			nameBar = cursor
+		}
+		previewPane = nameBar.Render(fileName) + m.previewHeader() + m.previewStatus() + "\n"
+		previewPane += previewContent
 	}
 
 	// Filter bar (green).
@@ -554,11 +944,10 @@ func (m *model) View() string {
 			filter = ""
 		}
 	}
-	barLen := strlen(location) + strlen(filter)
-	if barLen > outputWidth {
-		location = location[min(barLen-outputWidth, strlen(location)):]
-	}
-	barStr := bar.Render(location) + search.Render(filter)
+	// Location bar (grey).
+	var location string
+	location, m.layout.crumbs = m.breadcrumbs(max(1, outputWidth-strlen(filter)))
+	barStr := location + search.Render(filter)
 
 	main := barStr + "\n" + Join(output, "\n")
 
@@ -572,19 +961,23 @@ func (m *model) View() string {
 		// Only show one status bar.
 		// TODO: Show most recent status bar.
 		if len(m.toBeDeleted) > 0 {
-			toDelete := m.toBeDeleted[len(m.toBeDeleted)-1]
-			timeLeft := int(toDelete.at.Sub(time.Now()).Seconds())
-			deleteBar := fmt.Sprintf("%v deleted. (u)ndo %v", path.Base(toDelete.path), timeLeft)
-			main += "\n" + danger.Render(deleteBar)
+			main += "\n" + m.deleteBar()
 		} else if m.yankedFilePath != "" {
 			yankBar := fmt.Sprintf("copied: %v", m.yankedFilePath)
 			main += "\n" + bar.Render(yankBar)
+		} else if m.message != "" {
+			main += "\n" + bar.Render(m.message)
+		} else if m.diffLeft != "" {
+			main += "\n" + bar.Render(fmt.Sprintf("compare %v with: press = on another file", path.Base(m.diffLeft)))
+		} else if m.dupes != nil {
+			main += "\n" + bar.Render(m.dupes.status())
 		} else if m.statusBar != nil {
 			f, ok := m.currentFile()
 			if ok {
 				env := Env{
 					Files:       m.files,
 					CurrentFile: f,
+					Path:        path.Join(m.path, f.Name()),
 				}
 				statusBar, err := expr.Run(m.statusBar, env)
 				if err != nil {
@@ -596,7 +989,9 @@ func (m *model) View() string {
 		}
 	}
 
-	view := main
+	view := m.eraseGraphics(m.previewImage) + main
+	m.layout.width = lipgloss.Width(main)
+	m.layout.height = Count(main, "\n") + 1
 	if m.previewMode {
 		previewStyle := previewPlain
 		if withBorder {
@@ -727,6 +1122,8 @@ files:
 		}
 		m.files = append(m.files, file)
 	}
+	sortFiles(m.files, m.sortMode)
+	m.links = readLinks(m.path, m.files)
 }
 
 func (m *model) listHeight() int {
@@ -744,6 +1141,15 @@ func (m *model) showStatusBar() bool {
 	if m.yankedFilePath != "" {
 		return true
 	}
+	if m.message != "" {
+		return true
+	}
+	if m.diffLeft != "" {
+		return true
+	}
+	if m.dupes != nil {
+		return true
+	}
 	if m.statusBar != nil {
 		return true
 	}
@@ -810,7 +1216,11 @@ func (m *model) open() tea.Cmd {
 	var commandString string
 	if commandString, ok = openWith[extension(filePath)]; ok {
 	} else {
-		commandString = lookup([]string{"WALK_EDITOR", "EDITOR"}, "less")
+		commandString = lookup([]string{"WALK_EDITOR", "EDITOR"}, "")
+	}
+	if commandString == "" {
+		// No editor configured, use built-in pager.
+		return m.openPager(filePath)
 	}
 
 	commandSlice := append(Split(commandString, " "), filePath)
@@ -824,18 +1234,26 @@ func (m *model) open() tea.Cmd {
 }
 
 func (m *model) preview() {
-	if !m.previewMode {
+	m.previewImage = ""
+	m.previewInfo = ""
+	m.previewDepth = 0
+	if !m.previewMode && (m.dupes == nil || !m.dupes.open) {
 		return
 	}
-	filePath, ok := m.filePath()
+	filePath, ok := m.previewFile()
 	if !ok {
 		// Normally this should not happen
 		m.previewContent = warning.Render("Invalid file to preview")
 		return
 	}
+	m.resetPreviewScroll(filePath)
 
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
+		if target, err := os.Readlink(filePath); err == nil {
+			m.previewContent = warning.Render("Broken link to " + target)
+			return
+		}
 		m.previewContent = warning.Render(err.Error())
 		return
 	}
@@ -850,12 +1268,12 @@ func (m *model) preview() {
 			return
 		}
 
+		header := dirHeader(filePath, fileInfo.ModTime().UnixNano(), files)
 		if len(files) == 0 {
-			m.previewContent = warning.Render("No files")
+			m.previewContent = Join(header, "\n") + "\n\n" + warning.Render("No files")
 			return
 		}
-
-		names, rows, columns := wrap(files, width, height, nil)
+		names, rows, columns := wrap(files, readLinks(filePath, files), width, height-len(header)-1, nil)
 
 		output := make([]string, rows)
 		for j := 0; j < rows; j++ {
@@ -865,23 +1283,86 @@ func (m *model) preview() {
 			}
 			output[j] = Join(row, separator)
 		}
-		if len(output) >= height {
-			output = output[0:height]
+		m.previewContent = Join(header, "\n") + "\n\n" + Join(output, "\n")
+		return
+	}
+
+	if command := previewerFor(filePath); command != "" {
+		m.previewInfo = Fields(command)[0]
+		if key, ok := m.previewerKey(filePath); ok && previewerCache.key == key {
+			if previewerCache.err != nil {
+				m.previewContent = warning.Render(previewerCache.err.Error())
+			} else {
+				m.previewContent = previewerCache.output
+			}
+		} else {
+			m.previewContent = lineNumber.Render("Loading…")
 		}
-		m.previewContent = Join(output, "\n")
 		return
 	}
 
 	if isImage(filePath) {
-		img, err := drawImage(filePath, width, height)
+		img, info, err := drawImage(filePath, width, height)
 		if err != nil {
 			m.previewContent = warning.Render("No image preview available")
 			return
 		}
 		m.previewContent = img
+		m.previewInfo = info
+		if a := m.animation; a != nil && a.path == filePath && a.playing() {
+			m.previewContent = a.frame(width, height)
+			m.previewInfo += fmt.Sprintf(" %d frames", len(a.gif.Image))
+		}
+		if graphics != graphicsNone {
+			m.previewImage = filePath
+		}
+		return
+	}
+
+	if a := readAudio(filePath); a != nil {
+		m.previewContent = a.preview(width, height)
+		m.previewInfo = a.summary()
+		if a.cover != nil && graphics != graphicsNone {
+			m.previewImage = filePath
+		}
 		return
 	}
 
+	if out, info, err := previewExecutable(filePath); err != errNotExecutable {
+		if err != nil {
+			m.previewContent = warning.Render(err.Error())
+			return
+		}
+		m.previewContent = out
+		m.previewInfo = info
+		return
+	}
+
+	if out, info, err := previewArchive(filePath); err != errNotArchive {
+		if err == errArchiveReading {
+			m.previewContent = lineNumber.Render("Reading…")
+			return
+		}
+		if err != nil {
+			m.previewContent = warning.Render(err.Error())
+			return
+		}
+		m.previewContent = out
+		m.previewInfo = info
+		return
+	}
+
+	if structuredFormat(filePath) != "" && m.isRendered(filePath) {
+		// Fall back to text preview if the file cannot be parsed.
+		out, info, depth, err := renderStructured(filePath, m.previewFold)
+		if err == nil {
+			m.previewContent = out
+			m.previewInfo = info
+			m.previewDepth = depth
+			return
+		}
+	}
+
 	var content []byte
 	// If file is too big (> 100kb), read only first 100kb.
 	if fileInfo.Size() > 100*1024 {
@@ -892,11 +1373,12 @@ func (m *model) preview() {
 		}
 		defer file.Close()
 		content = make([]byte, 100*1024)
-		_, err = file.Read(content)
-		if err != nil {
+		n, err := io.ReadFull(file, content)
+		if err != nil && err != io.ErrUnexpectedEOF {
 			m.previewContent = err.Error()
 			return
 		}
+		content = content[:n]
 	} else {
 		content, err = os.ReadFile(filePath)
 		if err != nil {
@@ -905,28 +1387,96 @@ func (m *model) preview() {
 		}
 	}
 
-	switch {
-	case utf8.Valid(content):
-		previewContent := leaveOnlyAscii(content)
-		m.previewContent = previewContent
-
-		if withHighlight {
-			var buf bytes.Buffer
-			lexer := lexers.Match(filePath)
-			if lexer == nil {
-				lexer = lexers.Fallback
-			}
-			if err := quick.Highlight(&buf, previewContent, lexer.Config().Name, "terminal256", "friendly"); err == nil {
-				m.previewContent = buf.String()
-			}
-		}
-	default:
+	text, encoding := decodeText(content)
+	if encoding == "" {
 		m.previewContent = warning.Render("No preview available")
+		return
+	}
+	m.previewInfo = encoding
+
+	if isMarkdown(filePath) && m.isRendered(filePath) {
+		if out, err := renderMarkdown(text, width-4); err == nil {
+			m.previewContent = out
+			return
+		}
+	}
+
+	m.previewContent = highlight(filePath, sanitize(text))
+}
+
+// previewFile returns path of the file to preview: selected duplicate when
+// duplicates are listed, otherwise the selected file.
+func (m *model) previewFile() (string, bool) {
+	if m.dupes != nil && m.dupes.open {
+		return m.dupes.selection(m)
+	}
+	return m.filePath()
+}
+
+// isRendered reports whether file is previewed rendered instead of source.
+// Data files are rendered by default, markdown is not.
+func (m *model) isRendered(filePath string) bool {
+	if rendered, ok := m.rendered[extension(filePath)]; ok {
+		return rendered
+	}
+	return structuredFormat(filePath) != ""
+}
+
+// previewCmd returns command needed by preview after the model was updated.
+func (m *model) previewCmd() tea.Cmd {
+	var cmds []tea.Cmd
+	filePath, ok := m.previewFile()
+	dupesOpen := m.dupes != nil && m.dupes.open
+	visible := ok && (m.previewMode || dupesOpen) && m.pager == nil && m.du == nil && m.diff == nil && !m.showHelp
+
+	// Images drawn with iTerm2 or Sixel graphics are only removed when the
+	// cells under them are redrawn.
+	if (graphics == graphicsITerm || graphics == graphicsSixel) && m.imageShown != "" {
+		if !visible || filePath != m.imageShown {
+			m.imageShown = ""
+			cmds = append(cmds, tea.ClearScreen)
+		}
+	}
+
+	// Play animated GIFs only while they are visible in preview.
+	if !visible || (m.animation != nil && m.animation.path != filePath) {
+		m.animation = nil
+	}
+	if visible && m.animation == nil && imageFormat(filePath) == "gif" {
+		var cmd tea.Cmd
+		m.animation, cmd = loadAnimation(filePath)
+		cmds = append(cmds, cmd)
+	}
+
+	if visible {
+		cmds = append(cmds, runListArchive(filePath))
+	}
+
+	// Directories are listed in preview, previewers are only for files.
+	previewer := false
+	if visible {
+		fi, err := fileInfo(filePath)
+		previewer = err == nil && !fi.IsDir()
+	}
+	if previewer {
+		cmds = append(cmds, m.runPreviewer(filePath))
+	} else {
+		m.cancelPreviewer()
 	}
+
+	// Compute recursive size of the previewed directory only while it is
+	// selected.
+	if visible {
+		cmds = append(cmds, m.runDirStats(filePath))
+	} else {
+		m.cancelDirStats()
+	}
+
+	return tea.Batch(cmds...)
 }
 
 // TODO: Write tests for this function.
-func wrap(files []os.DirEntry, width int, height int, callback func(name string, i, j int)) ([][]string, int, int) {
+func wrap(files []os.DirEntry, links linkTargets, width int, height int, callback func(name string, i, j int)) ([][]string, int, int) {
 	// If the directory is empty, return no names, rows and columns.
 	if len(files) == 0 {
 		return nil, 0, 0
@@ -984,6 +1534,8 @@ start:
 			if files[n].IsDir() {
 				// Dirs should have a slash at the end.
 				name += fileSeparator
+			} else if l, ok := links[files[n].Name()]; ok {
+				name += " -> " + l.target
 			}
 
 			n++ // Next file.
@@ -1033,6 +1585,25 @@ start:
 	return names, rows, columns
 }
 
+func (m *model) deleteBar() string {
+	toDelete := m.toBeDeleted[len(m.toBeDeleted)-1]
+	timeLeft := int(toDelete.at.Sub(time.Now()).Seconds())
+	deleteBar := fmt.Sprintf("%v deleted. (u)ndo %v", path.Base(toDelete.path), timeLeft)
+	return danger.Render(deleteBar)
+}
+
+// undoDelete cancels the last pending deletion. Returns false if there is
+// nothing to undo.
+func (m *model) undoDelete() bool {
+	if len(m.toBeDeleted) == 0 {
+		return false
+	}
+	m.toBeDeleted = m.toBeDeleted[:len(m.toBeDeleted)-1]
+	m.list()
+	m.previewContent = ""
+	return true
+}
+
 func (m *model) dontDoPendingDeletions() {
 	for _, toDelete := range m.toBeDeleted {
 		fmt.Fprintf(os.Stderr, "Was not deleted: %v\n", toDelete.path)
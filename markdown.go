@@ -0,0 +1,39 @@
+package main
+
+import (
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	markdownRenderer *glamour.TermRenderer
+	markdownWidth    int
+)
+
+func isMarkdown(path string) bool {
+	ext := extension(path)
+	return ext == "md" || ext == "markdown"
+}
+
+func renderMarkdown(content string, width int) (string, error) {
+	// Creating a renderer is expensive, so reuse it until the width changes.
+	if markdownRenderer == nil || markdownWidth != width {
+		style := "light"
+		if !withHighlight {
+			style = "notty"
+		} else if darkBackground {
+			style = "dark"
+		}
+		r, err := glamour.NewTermRenderer(
+			glamour.WithStandardStyle(style),
+			glamour.WithColorProfile(lipgloss.ColorProfile()),
+			glamour.WithWordWrap(width),
+		)
+		if err != nil {
+			return "", err
+		}
+		markdownRenderer = r
+		markdownWidth = width
+	}
+	return markdownRenderer.Render(content)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	doubleClickTime = 400 * time.Millisecond
+	wheelStep       = 3 // Lines of preview to scroll per wheel step.
+)
+
+// layout keeps positions of what View has drawn, to find what is under the
+// mouse.
+type layout struct {
+	columns []int       // Horizontal positions of listing columns.
+	width   int         // Width of the listing, the preview pane is to the right.
+	crumbs  []crumbSpan // Directories in the location bar.
+	height  int         // Lines in the whole view.
+}
+
+type mouseClick struct {
+	path string
+	at   time.Time
+}
+
+func (m *model) updateMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	if msg.Action != tea.MouseActionPress || m.showHelp {
+		return m, nil
+	}
+
+	// Other modes are made for the keyboard, but the wheel scrolls them
+	// like arrow keys do.
+	if m.pager != nil || m.du != nil || m.diff != nil || m.checksums != nil || m.chmod != nil || m.link != nil || m.prompt != nil || m.palette != nil || (m.dupes != nil && m.dupes.open) {
+		switch msg.Button {
+		case tea.MouseButtonWheelUp:
+			return m.update(tea.KeyMsg{Type: tea.KeyUp})
+		case tea.MouseButtonWheelDown:
+			return m.update(tea.KeyMsg{Type: tea.KeyDown})
+		}
+		return m, nil
+	}
+
+	overPreview := m.previewMode && msg.X >= m.layout.width
+	switch msg.Button {
+	case tea.MouseButtonWheelUp:
+		if overPreview {
+			m.scrollPreview(-wheelStep)
+			return m, nil
+		}
+		m.moveUp()
+
+	case tea.MouseButtonWheelDown:
+		if overPreview {
+			m.scrollPreview(wheelStep)
+			return m, nil
+		}
+		m.moveDown()
+
+	case tea.MouseButtonLeft:
+		if overPreview {
+			return m, nil
+		}
+		return m.click(msg.X, msg.Y)
+
+	default:
+		return m, nil
+	}
+	m.updateOffset()
+	m.saveCursorPosition()
+	return m, nil
+}
+
+// click moves the cursor to the clicked file, or opens it on double click.
+func (m *model) click(x, y int) (tea.Model, tea.Cmd) {
+	// Without alt screen, the view is drawn below the shell prompt, and
+	// its position is known only if it fills the terminal.
+	if !m.previewMode {
+		if m.layout.height < m.termHeight {
+			return m, nil
+		}
+		y += m.layout.height - m.termHeight
+	}
+
+	if y == 0 {
+		m.clickLocation(x)
+		return m, nil
+	}
+
+	j := y - 1 + m.offset
+	if y-1 >= m.listHeight() || j >= m.rows || len(m.layout.columns) == 0 {
+		return m, nil
+	}
+	i := 0
+	for i+1 < len(m.layout.columns) && m.layout.columns[i+1] <= x {
+		i++
+	}
+	n := i*m.rows + j
+	if n >= len(m.files) || i >= m.columns {
+		return m, nil
+	}
+	m.c, m.r = i, j
+	filePath, _ := m.filePath()
+	double := m.lastClick.path == filePath && time.Since(m.lastClick.at) < doubleClickTime
+	m.lastClick = mouseClick{path: filePath, at: time.Now()}
+	if double {
+		m.lastClick = mouseClick{}
+		return m.update(tea.KeyMsg{Type: tea.KeyEnter})
+	}
+	m.deleteCurrentFile = false
+	m.saveCursorPosition()
+	return m, nil
+}
+
+// clickLocation goes to the directory clicked in the location bar.
+func (m *model) clickLocation(x int) {
+	for _, span := range m.layout.crumbs {
+		if x >= span.x && x < span.x+span.width {
+			m.breadcrumbsFocus = false
+			m.goToAncestor(span.path)
+			return
+		}
+	}
+}
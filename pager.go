@@ -0,0 +1,448 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const (
+	pagerChunkSize   = 64 * 1024
+	pagerMaxLineSize = 64 * 1024 // Longer lines are cut.
+	pagerSearchBatch = 1000      // Number of lines read at once while searching.
+	pagerFollowDelay = 500 * time.Millisecond
+	pagerSearchStep  = 50 * time.Millisecond // Longest search between handling key presses.
+)
+
+// pager is a full-screen file viewer. The file is never loaded whole: lines
+// are indexed lazily, only as far as the viewer needs, and read on demand.
+type pager struct {
+	path     string
+	file     *os.File
+	encoding textEncoding
+	lines    []int64 // Start offsets of lines indexed so far.
+	indexed  int64   // Number of bytes indexed.
+	eof      bool    // Whether end of file was reached by indexing.
+	top      int     // First visible line.
+	left     int     // Horizontal scroll position.
+	wrap     bool    // Whether long lines are wrapped.
+	numbers  bool    // Whether line numbers are shown.
+	follow   bool    // Whether to keep reading the file as it grows.
+	followId int     // Id of the follow tick loop, to stop stale loops.
+	search   string  // Last search query.
+	findDir  int     // Direction of the search in progress, or 0.
+	findAt   int     // Line the search in progress resumes from.
+	findId   int     // Id of the search in progress, to stop stale steps.
+	prompt   string  // Prompt being typed: "/" for search or ":" for goto line.
+	input    string  // Text typed into the prompt.
+	message  string  // Message to show in the status line.
+	height   int     // Number of visible lines.
+}
+
+type (
+	pagerTickMsg   int
+	pagerSearchMsg int
+)
+
+func newPager(path string) *pager {
+	p := &pager{
+		path:  path,
+		lines: []int64{0},
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		p.message = err.Error()
+		p.eof = true
+		return p
+	}
+	p.file = file
+	head := make([]byte, pagerChunkSize)
+	n, _ := file.ReadAt(head, 0)
+	p.encoding = detectEncoding(head[:n])
+	return p
+}
+
+func (p *pager) close() {
+	if p.file != nil {
+		_ = p.file.Close()
+	}
+}
+
+// index reads the file until line n is known or the end of file is reached.
+func (p *pager) index(n int) {
+	if p.file == nil {
+		return
+	}
+	newline := p.encoding.newline()
+	buf := make([]byte, pagerChunkSize)
+	for !p.eof && len(p.lines) <= n {
+		k, err := p.file.ReadAt(buf, p.indexed)
+		k -= k % len(newline) // Keep to whole UTF-16 code units.
+		for i := 0; i < k; i += len(newline) {
+			if bytes.Equal(buf[i:i+len(newline)], newline) {
+				p.lines = append(p.lines, p.indexed+int64(i+len(newline)))
+			}
+		}
+		p.indexed += int64(k)
+		if err != nil {
+			p.eof = true
+		}
+	}
+}
+
+// total returns number of lines indexed so far.
+func (p *pager) total() int {
+	n := len(p.lines)
+	if p.lines[n-1] == p.indexed {
+		// File ends with a newline, or the line is not read yet.
+		n--
+	}
+	return n
+}
+
+// rawLines returns lines decoded to UTF-8, but not highlighted yet.
+func (p *pager) rawLines(from, to int) []string {
+	p.index(to)
+	to = min(to, p.total())
+	if p.file == nil || from >= to {
+		return nil
+	}
+	lines := make([]string, 0, to-from)
+	for i := from; i < to; i++ {
+		start, end := p.lines[i], p.indexed
+		if i+1 < len(p.lines) {
+			end = p.lines[i+1]
+		}
+		buf := make([]byte, min(end-start, pagerMaxLineSize))
+		n, err := p.file.ReadAt(buf, start)
+		if err != nil && err != io.EOF {
+			p.message = err.Error()
+			break
+		}
+		lines = append(lines, strings.TrimRight(p.encoding.decode(buf[:n]), "\r\n"))
+	}
+	return lines
+}
+
+func (p *pager) lastTop() int {
+	return max(0, p.total()-p.height)
+}
+
+func (p *pager) scroll(n int) {
+	p.top += n
+	p.index(p.top + p.height)
+	p.top = min(p.top, p.lastTop())
+	p.top = max(p.top, 0)
+}
+
+func (p *pager) bottom() {
+	p.index(math.MaxInt)
+	p.top = p.lastTop()
+}
+
+// find looks for the first line matching the query, starting from line
+// "from" and moving in direction dir. Returns the matching line and done.
+// If the deadline passes first, returns the line to resume from, and done
+// is false.
+func (p *pager) find(query string, from, dir int, deadline time.Time) (line int, found, done bool) {
+	if dir > 0 {
+		for i := from; ; i += pagerSearchBatch {
+			lines := p.rawLines(i, i+pagerSearchBatch)
+			if len(lines) == 0 {
+				return 0, false, true
+			}
+			for j, line := range lines {
+				if indexMatch(line, query) >= 0 {
+					return i + j, true, true
+				}
+			}
+			if time.Now().After(deadline) {
+				return i + pagerSearchBatch, false, false
+			}
+		}
+	}
+	for i := from; i >= 0; i -= pagerSearchBatch {
+		start := max(0, i-pagerSearchBatch+1)
+		lines := p.rawLines(start, i+1)
+		for j := len(lines) - 1; j >= 0; j-- {
+			if indexMatch(lines[j], query) >= 0 {
+				return start + j, true, true
+			}
+		}
+		if time.Now().After(deadline) && start > 0 {
+			return start - 1, false, false
+		}
+	}
+	return 0, false, true
+}
+
+// jump starts searching for the next or previous match.
+func (p *pager) jump(dir int) tea.Cmd {
+	if p.search == "" {
+		return nil
+	}
+	p.findId++
+	p.findDir = dir
+	p.findAt = p.top + dir
+	return p.continueSearch()
+}
+
+// continueSearch searches for a while, then lets key presses be handled
+// before the next step, so that a search through a huge file can be
+// stopped.
+func (p *pager) continueSearch() tea.Cmd {
+	line, found, done := p.find(p.search, p.findAt, p.findDir, time.Now().Add(pagerSearchStep))
+	if !done {
+		p.findAt = line
+		id := p.findId
+		return func() tea.Msg {
+			return pagerSearchMsg(id)
+		}
+	}
+	p.findDir = 0
+	if !found {
+		p.message = "Pattern not found: " + p.search
+		return nil
+	}
+	p.top = line
+	p.scroll(0)
+	return nil
+}
+
+func (p *pager) tick() tea.Cmd {
+	id := p.followId
+	return tea.Tick(pagerFollowDelay, func(time.Time) tea.Msg {
+		return pagerTickMsg(id)
+	})
+}
+
+// reload picks up data appended to the file since it was indexed. If the file
+// was truncated, it is indexed from scratch.
+func (p *pager) reload() {
+	if p.file == nil {
+		return
+	}
+	info, err := p.file.Stat()
+	if err != nil {
+		p.message = err.Error()
+		return
+	}
+	if info.Size() < p.indexed {
+		p.lines = []int64{0}
+		p.indexed = 0
+	}
+	p.eof = false
+	p.bottom()
+}
+
+func (p *pager) view(width, height int) string {
+	p.height = height - 1 // Subtract 1 for status line.
+
+	raw := p.rawLines(p.top, p.top+p.height)
+	rendered := strings.Split(highlight(p.path, sanitize(strings.Join(raw, "\n"))), "\n")
+
+	gutter := 0
+	if p.numbers {
+		gutter = len(fmt.Sprint(p.top+p.height)) + 1
+	}
+
+	rows := make([]string, 0, p.height)
+	for i := 0; i < len(raw) && len(rows) < p.height; i++ {
+		line := ""
+		if i < len(rendered) {
+			line = rendered[i]
+		}
+		if p.search != "" && indexMatch(raw[i], p.search) >= 0 {
+			line = highlightMatches(sanitize(raw[i]), p.search, search.Render)
+		}
+		number := ""
+		if p.numbers {
+			number = lineNumber.Render(fmt.Sprintf("%*d ", gutter-1, p.top+i+1))
+		}
+		if !p.wrap {
+			rows = append(rows, number+ansi.Cut(line, p.left, p.left+width-gutter))
+			continue
+		}
+		for j, part := range strings.Split(ansi.Hardwrap(line, max(1, width-gutter), true), "\n") {
+			if len(rows) >= p.height {
+				break
+			}
+			if j > 0 {
+				number = strings.Repeat(" ", gutter)
+			}
+			rows = append(rows, number+part)
+		}
+	}
+	for len(rows) < p.height {
+		rows = append(rows, lineNumber.Render("~"))
+	}
+
+	return strings.Join(rows, "\n") + "\n" + p.status(width)
+}
+
+func (p *pager) status(width int) string {
+	if p.prompt != "" {
+		return search.Render(p.prompt + p.input)
+	}
+	total := strconv.Itoa(p.total())
+	if !p.eof {
+		total += "+"
+	}
+	status := fmt.Sprintf("%v  %d-%d/%v", filepath.Base(p.path), p.top+1, min(p.top+p.height, p.total()), total)
+	if p.wrap {
+		status += "  wrap"
+	}
+	if p.follow {
+		status += "  follow"
+	}
+	if p.findDir != 0 {
+		status += fmt.Sprintf("  searching line %d…", p.findAt+1)
+	}
+	if p.message != "" {
+		status += "  " + p.message
+	}
+	return bar.Render(ansi.Truncate(status, width, "…"))
+}
+
+func (m *model) openPager(filePath string) tea.Cmd {
+	m.pager = newPager(filePath)
+	m.pager.numbers = m.lineNumbers
+	if m.previewMode {
+		return nil // Already in alt screen.
+	}
+	return tea.EnterAltScreen
+}
+
+func (m *model) closePager() tea.Cmd {
+	m.pager.close()
+	m.pager = nil
+	if m.previewMode {
+		return nil
+	}
+	return tea.ExitAltScreen
+}
+
+func (m *model) updatePager(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := m.pager
+	p.message = ""
+
+	if key.Matches(msg, keyForceQuit) {
+		m.quitting = true
+		m.exitCode = 2
+		m.dontDoPendingDeletions()
+		return m, tea.Quit
+	}
+
+	if p.findDir != 0 {
+		// Any key stops the search in progress.
+		p.findDir = 0
+		p.message = "Search stopped"
+		return m, nil
+	}
+
+	if p.prompt != "" {
+		switch {
+		case key.Matches(msg, keyQuit):
+			p.prompt = ""
+		case key.Matches(msg, keyBack):
+			if len(p.input) > 0 {
+				r := []rune(p.input)
+				p.input = string(r[:len(r)-1])
+			} else {
+				p.prompt = ""
+			}
+		case key.Matches(msg, keyOpen):
+			prompt, input := p.prompt, p.input
+			p.prompt, p.input = "", ""
+			if prompt == "/" {
+				p.search = input
+				return m, p.jump(1)
+			}
+			n, err := strconv.Atoi(input)
+			if err != nil {
+				p.message = "Invalid line number: " + input
+				break
+			}
+			p.index(n)
+			p.top = max(0, min(n-1, p.total()-1))
+			p.scroll(0)
+		case msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace:
+			p.input += string(msg.Runes)
+		}
+		return m, nil
+	}
+
+	// Any key press other than follow stops following.
+	if !key.Matches(msg, keyPagerFollow) {
+		p.follow = false
+	}
+
+	switch {
+	case key.Matches(msg, keyQuit, keyQuitQ):
+		return m, m.closePager()
+
+	case key.Matches(msg, keyUp, keyVimUp):
+		p.scroll(-1)
+
+	case key.Matches(msg, keyDown, keyVimDown, keyOpen):
+		p.scroll(1)
+
+	case key.Matches(msg, keyPageUp, keyTop, keyBack):
+		p.scroll(-p.height)
+
+	case key.Matches(msg, keyPageDown, keyBottom, keyPreview):
+		p.scroll(p.height)
+
+	case key.Matches(msg, keyHome, keyVimTop):
+		p.top = 0
+
+	case key.Matches(msg, keyEnd, keyVimBottom):
+		p.bottom()
+
+	case key.Matches(msg, keyLeft, keyVimLeft):
+		p.left = max(0, p.left-horizontalScrollStep)
+
+	case key.Matches(msg, keyRight, keyVimRight):
+		p.left += horizontalScrollStep
+
+	case key.Matches(msg, keyLeftmost):
+		p.left = 0
+
+	case key.Matches(msg, keyPagerWrap):
+		p.wrap = !p.wrap
+		p.left = 0
+
+	case key.Matches(msg, keyLineNumbers):
+		p.numbers = !p.numbers
+
+	case key.Matches(msg, keySearch):
+		p.prompt, p.input = "/", ""
+
+	case key.Matches(msg, keyPagerGoto):
+		p.prompt, p.input = ":", ""
+
+	case key.Matches(msg, keyNextMatch):
+		return m, p.jump(1)
+
+	case key.Matches(msg, keyPrevMatch):
+		return m, p.jump(-1)
+
+	case key.Matches(msg, keyPagerFollow):
+		p.follow = true
+		p.followId++
+		p.reload()
+		return m, p.tick()
+	}
+
+	return m, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPagerFindResumes(t *testing.T) {
+	var lines []string
+	for i := 0; i < 5*pagerSearchBatch; i++ {
+		lines = append(lines, fmt.Sprint("line ", i))
+	}
+	lines[2500] = "match" // In the third batch from either end.
+	path := filepath.Join(t.TempDir(), "log.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := newPager(path)
+	defer p.close()
+
+	for _, dir := range []int{1, -1} {
+		from := 0
+		if dir < 0 {
+			from = len(lines) - 1
+		}
+		// With the deadline passed, every call reads one batch.
+		steps := 0
+		for {
+			line, found, done := p.find("match", from, dir, time.Time{})
+			steps++
+			if done {
+				if !found || line != 2500 {
+					t.Errorf("Failed: dir %d: found %v at %d", dir, found, line)
+				}
+				break
+			}
+			from = line
+		}
+		if steps < 2 {
+			t.Errorf("Failed: dir %d: search did not stop at the deadline", dir)
+		}
+	}
+}
+
+func TestPagerUTF16Lines(t *testing.T) {
+	// "Ċ" is U+010A, which has the "\n" byte in its code unit.
+	content := "\xff\xfe" + "a\x00\x0a\x01b\x00\r\x00\n\x00" + "c\x00\n\x00"
+	path := filepath.Join(t.TempDir(), "utf16.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := newPager(path)
+	defer p.close()
+
+	lines := p.rawLines(0, 10)
+	if len(lines) != 2 || lines[0] != "aĊb" || lines[1] != "c" {
+		t.Errorf("Failed: %q", lines)
+	}
+}
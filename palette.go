@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/sahilm/fuzzy"
+)
+
+// action is a command of the command palette. Actions without run press
+// their key in the main view.
+type action struct {
+	name  string
+	args  string // Usage of arguments, like "<path>".
+	desc  string
+	key   key.Binding
+	run   func(m *model, args []string) (tea.Model, tea.Cmd)
+	exact bool // Runs only if the name is typed in full.
+}
+
+var actions []action
+
+// Actions are set in init, as some of them call handleKey, which opens
+// the palette listing them.
+func init() {
+	actions = []action{
+		{name: "open", desc: "Open file or enter directory", key: keyOpen},
+		{name: "back", desc: "Exit directory", key: keyBack},
+		{name: "cd", args: "<path>", desc: "Go to path", key: keyGoTo, run: runCd},
+		{name: "location", desc: "Focus location bar", key: keyBreadcrumbs},
+		{name: "follow", desc: "Follow symlink", key: keyFollow},
+		{name: "search", desc: "Fuzzy search", key: keySearch},
+		{name: "preview", desc: "Toggle preview", key: keyPreview},
+		{name: "focus-preview", desc: "Focus preview", key: keyPreviewFocus},
+		{name: "render", desc: "Render preview", key: keyRender},
+		{name: "view", desc: "View file", key: keyView},
+		{name: "hidden", desc: "Toggle hidden files", key: keyHidden},
+		{name: "sort", desc: "Change sort order", key: keySort},
+		{name: "select", desc: "Select file", key: keySelect},
+		{name: "yank", desc: "Yank current path", key: keyYank},
+		{name: "delete", desc: "Delete file or directory", key: keyDelete, run: runDelete, exact: true},
+		{name: "undo", desc: "Undo delete", key: keyUndo, run: runUndo},
+		{name: "chmod", desc: "Change permissions", key: keyChmod},
+		{name: "link", desc: "Create links", key: keyLink},
+		{name: "checksum", desc: "Checksums", key: keyChecksum},
+		{name: "verify", desc: "Verify checksums", key: keyVerify},
+		{name: "diff", desc: "Compare files", key: keyDiff},
+		{name: "disk-usage", desc: "Disk usage", key: keyDiskUsage},
+		{name: "duplicates", desc: "Find duplicates", key: keyDupes},
+		{name: "help", desc: "Show help", key: keyHelp},
+		{name: "quit", desc: "Exit with cd", key: keyQuit},
+		{name: "force-quit", desc: "Exit without cd", key: keyForceQuit, exact: true},
+	}
+}
+
+func runCd(m *model, args []string) (tea.Model, tea.Cmd) {
+	if len(args) == 0 {
+		m.openPathPrompt()
+		return m, nil
+	}
+	if err := m.goTo(expandPath(strings.Join(args, " "), m.path)); err != nil {
+		m.message = err.Error()
+	}
+	return m, nil
+}
+
+// runDelete presses delete twice, as the name typed in full is the
+// confirmation.
+func runDelete(m *model, _ []string) (tea.Model, tea.Cmd) {
+	m.handleKey(keyMsg(keyDelete))
+	return m.handleKey(keyMsg(keyDelete))
+}
+
+func runUndo(m *model, _ []string) (tea.Model, tea.Cmd) {
+	if !m.undoDelete() {
+		m.message = "nothing to undo"
+	}
+	return m, nil
+}
+
+// keyMsg returns a key press matching the binding.
+func keyMsg(binding key.Binding) tea.KeyMsg {
+	k := binding.Keys()[0]
+	for t := tea.KeyType(-128); t < 128; t++ {
+		if t != tea.KeyRunes && (tea.Key{Type: t}).String() == k {
+			return tea.KeyMsg{Type: t}
+		}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+}
+
+// commandPalette lists actions matching the typed name.
+type commandPalette struct {
+	input    string
+	matches  []int // Indexes of matching actions.
+	selected int
+	top      int
+}
+
+func (m *model) openPalette() {
+	m.palette = &commandPalette{}
+	m.palette.filter()
+}
+
+func (p *commandPalette) filter() {
+	name := strings.SplitN(p.input, " ", 2)[0]
+	p.matches = p.matches[:0]
+	if name == "" {
+		for i := range actions {
+			p.matches = append(p.matches, i)
+		}
+	} else if strings.Contains(p.input, " ") {
+		// Arguments are being typed, the name is complete.
+		for i, a := range actions {
+			if a.name == name {
+				p.matches = append(p.matches, i)
+			}
+		}
+	} else {
+		names := make([]string, len(actions))
+		for i, a := range actions {
+			names[i] = a.name
+		}
+		for _, match := range fuzzy.Find(name, names) {
+			p.matches = append(p.matches, match.Index)
+		}
+	}
+	p.selected, p.top = 0, 0
+}
+
+func (m *model) updatePalette(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := m.palette
+	switch {
+	case key.Matches(msg, keyForceQuit):
+		m.quitting = true
+		m.exitCode = 2
+		m.dontDoPendingDeletions()
+		return m, tea.Quit
+
+	case key.Matches(msg, keyQuit):
+		m.palette = nil
+
+	case key.Matches(msg, keyUp):
+		p.selected = max(0, p.selected-1)
+
+	case key.Matches(msg, keyDown):
+		p.selected = max(0, min(len(p.matches)-1, p.selected+1))
+
+	case key.Matches(msg, keyPaletteFill):
+		if len(p.matches) > 0 {
+			a := actions[p.matches[p.selected]]
+			p.input = a.name
+			if a.args != "" {
+				p.input += " "
+			}
+			p.filter()
+		}
+
+	case key.Matches(msg, keyOpen):
+		if len(p.matches) == 0 {
+			m.palette = nil
+			m.message = "unknown command: " + p.input
+			return m, nil
+		}
+		args := strings.Fields(p.input)
+		if len(args) > 0 {
+			args = args[1:]
+		}
+		a := actions[p.matches[p.selected]]
+		if a.exact && strings.SplitN(p.input, " ", 2)[0] != a.name {
+			// Fill in the name, so a destructive action takes a second
+			// enter to run, like pressing its key twice.
+			p.input = a.name
+			p.filter()
+			for i, match := range p.matches {
+				if actions[match].name == a.name {
+					p.selected = i
+				}
+			}
+			break
+		}
+		m.palette = nil
+		if a.run != nil {
+			return a.run(m, args)
+		}
+		return m.handleKey(keyMsg(a.key))
+
+	case key.Matches(msg, keyBack):
+		if len(p.input) == 0 {
+			m.palette = nil
+			break
+		}
+		runes := []rune(p.input)
+		p.input = string(runes[:len(runes)-1])
+		p.filter()
+
+	case msg.Type == tea.KeyCtrlU:
+		p.input = ""
+		p.filter()
+
+	case msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace:
+		p.input += string(msg.Runes)
+		p.filter()
+	}
+	return m, nil
+}
+
+func (m *model) paletteView() string {
+	p := m.palette
+	width := m.termWidth - 4 // Border and padding.
+	height := max(1, m.termHeight-6)
+	if p.selected < p.top {
+		p.top = p.selected
+	}
+	if p.selected >= p.top+height {
+		p.top = p.selected - height + 1
+	}
+
+	usage := func(a action) string {
+		if a.args != "" {
+			return a.name + " " + a.args
+		}
+		return a.name
+	}
+	usageWidth := 0
+	for _, a := range actions {
+		usageWidth = max(usageWidth, strlen(usage(a)))
+	}
+	lines := []string{search.Render(":"+p.input) + cursor.Render(" ")}
+	for i := p.top; i < min(len(p.matches), p.top+height); i++ {
+		a := actions[p.matches[i]]
+		keys := ""
+		if len(a.key.Keys()) > 0 {
+			keys = a.key.Keys()[0]
+			if keys == " " {
+				keys = "space"
+			}
+		}
+		line := fmt.Sprintf("%-*s  %-9s  %s", usageWidth, usage(a), keys, a.desc)
+		line = ansi.Truncate(line, width, "…")
+		if i == p.selected {
+			line = cursor.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	if len(p.matches) == 0 {
+		lines = append(lines, lineNumber.Render("No matching commands"))
+	}
+
+	box := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(mainColor).
+		Padding(0, 1).
+		Width(min(width, 60) + 2).
+		Render(strings.Join(lines, "\n"))
+	return lipgloss.Place(m.termWidth, m.termHeight, lipgloss.Center, lipgloss.Center, box)
+}
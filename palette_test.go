@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestActions(t *testing.T) {
+	names := make(map[string]bool)
+	for _, a := range actions {
+		if names[a.name] {
+			t.Errorf("Failed: duplicate action %q", a.name)
+		}
+		names[a.name] = true
+		if len(a.key.Keys()) > 0 && !key.Matches(keyMsg(a.key), a.key) {
+			t.Errorf("Failed: %q: key %q is not pressed", a.name, a.key.Keys()[0])
+		}
+	}
+}
+
+func TestPaletteExactName(t *testing.T) {
+	m := &model{}
+	m.openPalette()
+	m.updatePalette(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("fquit")})
+	m.updatePalette(keyMsg(keyOpen))
+	if m.quitting {
+		t.Fatalf("Failed: quit with %q typed", "fquit")
+	}
+	if m.palette == nil || m.palette.input != "force-quit" {
+		t.Fatalf("Failed: name is not filled in")
+	}
+	m.updatePalette(keyMsg(keyOpen))
+	if !m.quitting {
+		t.Errorf("Failed: not quit with name typed in full")
+	}
+}
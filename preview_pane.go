@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const horizontalScrollStep = 8
+
+func (m *model) updatePreviewFocus(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	height := m.previewHeight()
+
+	if key.Matches(msg, keyForceQuit) {
+		m.quitting = true
+		m.exitCode = 2
+		m.dontDoPendingDeletions()
+		return m, tea.Quit
+	}
+
+	if m.previewSearchMode {
+		switch {
+		case key.Matches(msg, keyOpen):
+			m.previewSearchMode = false
+			m.previewMatch = -1
+			m.jumpToMatch(1)
+		case key.Matches(msg, keyQuit):
+			m.previewSearchMode = false
+			m.previewSearch = ""
+		case key.Matches(msg, keyBack):
+			if len(m.previewSearch) > 0 {
+				r := []rune(m.previewSearch)
+				m.previewSearch = string(r[:len(r)-1])
+			} else {
+				m.previewSearchMode = false
+			}
+		case msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace:
+			m.previewSearch += string(msg.Runes)
+		}
+		return m, nil
+	}
+
+	switch {
+	case key.Matches(msg, keyPreviewFocus, keyQuit, keyQuitQ):
+		m.previewFocus = false
+
+	case key.Matches(msg, keyUp, keyVimUp):
+		m.scrollPreview(-1)
+
+	case key.Matches(msg, keyDown, keyVimDown):
+		m.scrollPreview(1)
+
+	case key.Matches(msg, keyPageUp, keyTop):
+		m.scrollPreview(-height)
+
+	case key.Matches(msg, keyPageDown, keyBottom):
+		m.scrollPreview(height)
+
+	case key.Matches(msg, keyHome, keyVimTop):
+		m.previewOffset = 0
+
+	case key.Matches(msg, keyEnd, keyVimBottom):
+		m.previewOffset = m.previewLines - height
+
+	case key.Matches(msg, keyLeft, keyVimLeft):
+		m.previewOffsetX = max(0, m.previewOffsetX-horizontalScrollStep)
+
+	case key.Matches(msg, keyRight, keyVimRight):
+		m.previewOffsetX += horizontalScrollStep
+
+	case key.Matches(msg, keyLeftmost):
+		m.previewOffsetX = 0
+
+	case key.Matches(msg, keyLineNumbers):
+		m.lineNumbers = !m.lineNumbers
+
+	case key.Matches(msg, keyFold):
+		if m.previewDepth > 1 {
+			if m.previewFold == 0 {
+				m.previewFold = m.previewDepth
+			}
+			m.previewFold = max(1, m.previewFold-1)
+		}
+
+	case key.Matches(msg, keyUnfold):
+		if m.previewFold > 0 {
+			m.previewFold++
+			if m.previewFold >= m.previewDepth {
+				m.previewFold = 0
+			}
+		}
+
+	case key.Matches(msg, keySearch):
+		m.previewSearchMode = true
+		m.previewSearch = ""
+		m.previewMatches = nil
+
+	case key.Matches(msg, keyNextMatch):
+		m.jumpToMatch(1)
+
+	case key.Matches(msg, keyPrevMatch):
+		m.jumpToMatch(-1)
+	}
+
+	m.clampPreviewOffset()
+	return m, nil
+}
+
+func (m *model) previewHeight() int {
+	return m.termHeight - 1 // Subtract 1 for name bar.
+}
+
+func (m *model) scrollPreview(n int) {
+	m.previewOffset += n
+	m.clampPreviewOffset()
+}
+
+func (m *model) clampPreviewOffset() {
+	if m.previewOffset > m.previewLines-m.previewHeight() {
+		m.previewOffset = m.previewLines - m.previewHeight()
+	}
+	if m.previewOffset < 0 {
+		m.previewOffset = 0
+	}
+}
+
+// jumpToMatch moves to the next (dir > 0) or previous (dir < 0) line
+// matching the preview search, wrapping around the content.
+func (m *model) jumpToMatch(dir int) {
+	if len(m.previewMatches) == 0 {
+		m.previewMatches = findMatches(strings.Split(m.previewContent, "\n"), m.previewSearch)
+	}
+	if len(m.previewMatches) == 0 {
+		return
+	}
+	m.previewMatch = (m.previewMatch + dir + len(m.previewMatches)) % len(m.previewMatches)
+	line := m.previewMatches[m.previewMatch]
+	// Keep the match a third down the pane, so context above it is visible.
+	m.previewOffset = line - m.previewHeight()/3
+	m.clampPreviewOffset()
+}
+
+func (m *model) resetPreviewScroll(filePath string) {
+	if m.previewPath == filePath {
+		return
+	}
+	m.previewPath = filePath
+	m.previewOffset = 0
+	m.previewOffsetX = 0
+	m.previewMatch = -1
+	m.previewMatches = nil
+	m.previewFold = 0
+}
+
+// previewView renders visible part of the preview content in the given
+// width and height, applying scroll offsets, line numbers and highlighting
+// search matches.
+func (m *model) previewView(width, height int) string {
+	lines := strings.Split(m.previewContent, "\n")
+	m.previewLines = len(lines)
+	m.clampPreviewOffset()
+	m.previewMatches = findMatches(lines, m.previewSearch)
+
+	current := -1
+	if m.previewMatch >= 0 && m.previewMatch < len(m.previewMatches) {
+		current = m.previewMatches[m.previewMatch]
+	}
+
+	gutter := 0
+	if m.lineNumbers {
+		gutter = len(fmt.Sprint(len(lines))) + 1
+	}
+
+	end := min(m.previewOffset+height, len(lines))
+	output := make([]string, 0, end-m.previewOffset)
+	for i := m.previewOffset; i < end; i++ {
+		line := lines[i]
+		if m.previewSearch != "" && containsMatch(line, m.previewSearch) {
+			style := search
+			if i == current {
+				style = cursor
+			}
+			line = highlightMatches(ansi.Strip(line), m.previewSearch, style.Render)
+		}
+		if m.previewOffsetX > 0 || ansi.StringWidth(line) > width-gutter {
+			line = ansi.Cut(line, m.previewOffsetX, m.previewOffsetX+width-gutter)
+		}
+		if m.lineNumbers {
+			line = lineNumber.Render(fmt.Sprintf("%*d ", gutter-1, i+1)) + line
+		}
+		output = append(output, line)
+	}
+	return strings.Join(output, "\n")
+}
+
+func (m *model) previewHeader() string {
+	if m.previewInfo == "" {
+		return ""
+	}
+	return bar.Render(" " + m.previewInfo)
+}
+
+// previewStatus describes scroll position and search state for the preview
+// name bar.
+func (m *model) previewStatus() string {
+	if m.previewSearchMode {
+		return search.Render("/" + m.previewSearch)
+	}
+	status := ""
+	if m.previewFocus {
+		status = bar.Render(fmt.Sprintf(" %d/%d", min(m.previewOffset+m.previewHeight(), m.previewLines), m.previewLines))
+	}
+	if m.previewSearch != "" {
+		n := 0
+		if m.previewMatch >= 0 {
+			n = m.previewMatch + 1
+		}
+		status += search.Render(fmt.Sprintf(" /%v %d/%d", m.previewSearch, n, len(m.previewMatches)))
+	}
+	return status
+}
+
+func findMatches(lines []string, query string) []int {
+	if query == "" {
+		return nil
+	}
+	var matches []int
+	for i, line := range lines {
+		if containsMatch(line, query) {
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
+
+func containsMatch(line, query string) bool {
+	return indexMatch(ansi.Strip(line), query) >= 0
+}
+
+// indexMatch uses smart case: search is case-insensitive unless the query
+// contains upper case letters.
+func indexMatch(s, query string) int {
+	if strings.ToLower(query) != query {
+		return strings.Index(s, query)
+	}
+	lower := strings.ToLower(s)
+	if len(lower) != len(s) {
+		// Lowering changed byte offsets, fall back to exact search.
+		return strings.Index(s, query)
+	}
+	return strings.Index(lower, query)
+}
+
+func highlightMatches(s, query string, render func(...string) string) string {
+	var out strings.Builder
+	for {
+		i := indexMatch(s, query)
+		if i < 0 || query == "" {
+			break
+		}
+		out.WriteString(s[:i])
+		out.WriteString(render(s[i : i+len(query)]))
+		s = s[i+len(query):]
+	}
+	out.WriteString(s)
+	return out.String()
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const maxPreviewerOutput = 1024 * 1024 // Output after this is dropped.
+
+var (
+	previewers       = make(map[string]string) // Commands per extension, glob pattern or MIME type.
+	previewerKeys    []string                  // Keys of previewers, in order of WALK_PREVIEWER.
+	previewerTimeout = 5 * time.Second
+)
+
+// parsePreviewers parses WALK_PREVIEWER, like
+// "pdf:pdftotext -layout $1 -;*.sqlite:sqlite3 $1 .schema;image/*:chafa".
+func parsePreviewers(s string) {
+	for _, pair := range strings.Split(s, ";") {
+		key, command, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		command = strings.TrimSpace(command)
+		if !ok || key == "" || command == "" {
+			continue
+		}
+		if _, ok := previewers[key]; !ok {
+			previewerKeys = append(previewerKeys, key)
+		}
+		previewers[key] = command
+	}
+}
+
+// previewerFor returns command to preview file with, if configured. Looks up
+// by extension first, then by glob patterns and MIME types.
+func previewerFor(filePath string) string {
+	if len(previewers) == 0 {
+		return ""
+	}
+	if command, ok := previewers[extension(filePath)]; ok {
+		return command
+	}
+	base := filepath.Base(filePath)
+	var mimeType string
+	for _, key := range previewerKeys {
+		if !strings.Contains(key, "/") {
+			if ok, _ := filepath.Match(key, base); ok {
+				return previewers[key]
+			}
+			continue
+		}
+		if mimeType == "" {
+			mimeType = detectMIME(filePath)
+		}
+		if ok, _ := path.Match(key, mimeType); ok {
+			return previewers[key]
+		}
+	}
+	return ""
+}
+
+func detectMIME(filePath string) string {
+	if t := mime.TypeByExtension(filepath.Ext(filePath)); t != "" {
+		t, _, _ = strings.Cut(t, ";")
+		return t
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	head := make([]byte, 512)
+	n, _ := file.Read(head)
+	t, _, _ := strings.Cut(http.DetectContentType(head[:n]), ";")
+	return t
+}
+
+// previewerKey identifies output of a previewer, which depends on the file
+// and the size of preview pane.
+type previewerKey struct {
+	path          string
+	modTime       int64
+	width, height int
+}
+
+type previewerMsg struct {
+	key    previewerKey
+	output string
+	err    error
+}
+
+// Output of the last previewer run.
+var previewerCache previewerMsg
+
+// runPreviewer starts the previewer of the file in background, unless its
+// output is already known or being waited for. The previewer of the file
+// shown before is stopped.
+func (m *model) runPreviewer(filePath string) tea.Cmd {
+	command := previewerFor(filePath)
+	if command == "" {
+		m.cancelPreviewer()
+		return nil
+	}
+	key, ok := m.previewerKey(filePath)
+	if !ok || previewerCache.key == key {
+		m.cancelPreviewer()
+		return nil
+	}
+	if m.previewerRunning == key {
+		return nil
+	}
+	m.cancelPreviewer()
+	ctx, cancel := context.WithCancel(context.Background())
+	m.previewerRunning = key
+	m.previewerCancel = cancel
+	return func() tea.Msg {
+		output, err := execPreviewer(ctx, command, key)
+		return previewerMsg{key: key, output: output, err: err}
+	}
+}
+
+func (m *model) cancelPreviewer() {
+	if m.previewerCancel != nil {
+		m.previewerCancel()
+	}
+	m.previewerRunning = previewerKey{}
+	m.previewerCancel = nil
+}
+
+func (m *model) previewerKey(filePath string) (previewerKey, bool) {
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return previewerKey{}, false
+	}
+	return previewerKey{
+		path:    filePath,
+		modTime: stat.ModTime().UnixNano(),
+		width:   m.termWidth / 2,
+		height:  m.previewHeight(),
+	}, true
+}
+
+// execPreviewer runs command with a shell. Path, width and height of the
+// preview are passed as arguments $1, $2, $3 and as environment variables.
+// If command does not use the path, it is appended. The command is killed
+// when ctx is cancelled.
+func execPreviewer(ctx context.Context, command string, key previewerKey) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, previewerTimeout)
+	defer cancel()
+
+	width, height := strconv.Itoa(key.width), strconv.Itoa(key.height)
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		if !strings.Contains(command, "%WALK_PREVIEW_PATH%") {
+			command += ` "%WALK_PREVIEW_PATH%"`
+		}
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		if !strings.Contains(command, "$1") && !strings.Contains(command, "${1}") && !strings.Contains(command, "WALK_PREVIEW_PATH") {
+			command += ` "$1"`
+		}
+		cmd = exec.CommandContext(ctx, "sh", "-c", command, "walk", key.path, width, height)
+	}
+	cmd.Env = append(os.Environ(),
+		"WALK_PREVIEW_PATH="+key.path,
+		"WALK_PREVIEW_WIDTH="+width,
+		"WALK_PREVIEW_HEIGHT="+height,
+	)
+	cmd.Dir = filepath.Dir(key.path)
+	// Don't wait for processes started by the command, which keep the
+	// output open.
+	cmd.WaitDelay = 100 * time.Millisecond
+
+	var stdout, stderr limitedBuffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if ctx.Err() == context.Canceled {
+		return "", ctx.Err()
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("previewer timed out after %s", previewerTimeout)
+	}
+	if err != nil && stdout.Len() == 0 {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return sanitizeColored(stdout.String()), nil
+}
+
+// limitedBuffer keeps up to maxPreviewerOutput bytes written to it.
+type limitedBuffer struct {
+	strings.Builder
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := maxPreviewerOutput - b.Len(); room > 0 {
+		b.Builder.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
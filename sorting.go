@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sortModes are orders of files in the listing. Files are sorted by name
+// by default, as returned by ReadDir.
+var sortModes = []string{"name", "size", "time", "ext"}
+
+// sortFiles sorts files by mode: largest or newest first, or by extension.
+func sortFiles(files []os.DirEntry, mode string) {
+	if mode == "" || mode == "name" {
+		return
+	}
+	infos := make(map[string]os.FileInfo, len(files))
+	info := func(f os.DirEntry) os.FileInfo {
+		fi, ok := infos[f.Name()]
+		if !ok {
+			fi, _ = f.Info()
+			infos[f.Name()] = fi
+		}
+		return fi
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := info(files[i]), info(files[j])
+		switch mode {
+		case "size":
+			if a != nil && b != nil {
+				return a.Size() > b.Size()
+			}
+		case "time":
+			if a != nil && b != nil {
+				return a.ModTime().After(b.ModTime())
+			}
+		case "ext":
+			return strings.ToLower(filepath.Ext(files[i].Name())) < strings.ToLower(filepath.Ext(files[j].Name()))
+		}
+		return false
+	})
+}
+
+// setSort changes sort mode, keeping the cursor on the same file.
+func (m *model) setSort(mode string) {
+	if name, ok := m.currentFileName(); ok {
+		m.prevName = name
+		m.findPrevName = true
+	}
+	m.sortMode = mode
+	m.message = "sorted by " + mode
+	m.list()
+}
+
+// nextSort returns the sort mode after the current one.
+func (m *model) nextSort() string {
+	for i, mode := range sortModes {
+		if mode == m.sortMode {
+			return sortModes[(i+1)%len(sortModes)]
+		}
+	}
+	return sortModes[1]
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"io/fs"
-	"math"
 	"time"
 
 	"github.com/expr-lang/expr"
@@ -21,6 +20,7 @@ func compile(code string) *vm.Program {
 type Env struct {
 	Files       []fs.DirEntry `expr:"files"`
 	CurrentFile fs.DirEntry   `expr:"current_file"`
+	Path        string        `expr:"path"`
 }
 
 func (e Env) Sprintf(format string, a ...any) string {
@@ -40,21 +40,7 @@ func (e Env) Size() string {
 	if err != nil {
 		return "N/A"
 	}
-	size := float64(info.Size())
-	if size == 0 {
-		return "0B"
-	}
-	units := []string{"B", "KB", "MB", "GB", "TB", "PB", "EB"}
-	base := math.Log(size) / math.Log(1024)
-	unitIndex := int(math.Floor(base))
-	if unitIndex >= len(units) {
-		unitIndex = len(units) - 1
-	}
-	value := size / math.Pow(1024, float64(unitIndex))
-	if unitIndex == 0 {
-		return fmt.Sprintf("%.0f%s", value, units[unitIndex])
-	}
-	return fmt.Sprintf("%.1f%s", value, units[unitIndex])
+	return humanSize(info.Size())
 }
 
 func (e Env) Mode() string {
@@ -62,9 +48,11 @@ func (e Env) Mode() string {
 	if err != nil {
 		return "?????????"
 	}
+	return modeString(info.Mode())
+}
 
-	mode := info.Mode()
-
+// modeString formats mode like ls does, for example -rwxr-sr-x.
+func modeString(mode fs.FileMode) string {
 	result := make([]byte, 10)
 
 	switch {
@@ -125,3 +113,70 @@ func (e Env) ModTime() string {
 	}
 	return modTime.Format("Jan 2 2006")
 }
+
+func (e Env) exif() exifInfo {
+	if x := readExif(e.Path); x != nil {
+		return *x
+	}
+	return exifInfo{}
+}
+
+func (e Env) Camera() string {
+	return e.exif().camera
+}
+
+func (e Env) Lens() string {
+	return e.exif().lens
+}
+
+func (e Env) Exposure() string {
+	return e.exif().exposure
+}
+
+func (e Env) DateTaken() string {
+	return e.exif().date
+}
+
+func (e Env) GPS() string {
+	return e.exif().gps
+}
+
+func (e Env) Orientation() string {
+	x := e.exif()
+	if x.orientation == 0 {
+		return ""
+	}
+	return orientationNames[x.orientation]
+}
+
+func (e Env) audio() audioInfo {
+	if a := readAudio(e.Path); a != nil {
+		return *a
+	}
+	return audioInfo{}
+}
+
+func (e Env) Title() string {
+	return e.audio().title
+}
+
+func (e Env) Artist() string {
+	return e.audio().artist
+}
+
+func (e Env) Album() string {
+	return e.audio().album
+}
+
+func (e Env) Track() string {
+	return e.audio().track
+}
+
+func (e Env) Duration() string {
+	return formatDuration(e.audio().duration)
+}
+
+func (e Env) Bitrate() string {
+	a := e.audio()
+	return a.bitrateString()
+}
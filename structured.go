@@ -0,0 +1,580 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	maxStructuredSize = 1024 * 1024 // Bigger files are previewed as text.
+	maxColumnWidth    = 40          // Longer table cells are truncated.
+	maxYAMLNodes      = 100000      // Limit of nodes after expanding aliases.
+)
+
+var errTooBig = errors.New("file is too big")
+
+// structuredFormat returns format of data files which have a structured
+// preview, or empty string.
+func structuredFormat(path string) string {
+	switch extension(path) {
+	case "json":
+		return "json"
+	case "yaml", "yml":
+		return "yaml"
+	case "toml":
+		return "toml"
+	case "csv":
+		return "csv"
+	case "tsv":
+		return "tsv"
+	}
+	return ""
+}
+
+type nodeKind int
+
+const (
+	nodeObject nodeKind = iota
+	nodeArray
+	nodeString
+	nodeNumber
+	nodeBool
+	nodeNull
+	nodeAlias // Recursive YAML alias, like *anchor.
+)
+
+// treeNode is a parsed value of JSON, YAML or TOML document. Keys of objects
+// are kept in the source order.
+type treeNode struct {
+	key      string
+	kind     nodeKind
+	value    string // Scalar value.
+	children []*treeNode
+}
+
+func (n *treeNode) container() bool {
+	return n.kind == nodeObject || n.kind == nodeArray
+}
+
+// depth returns number of nested container levels.
+func (n *treeNode) depth() int {
+	if !n.container() {
+		return 0
+	}
+	d := 0
+	for _, c := range n.children {
+		d = max(d, c.depth())
+	}
+	return d + 1
+}
+
+type structuredEntry struct {
+	path    string
+	modTime int64
+	format  string
+	roots   []*treeNode // Documents of tree formats.
+	records [][]string  // Rows of tables.
+	err     error
+	fold    int
+	content string
+	info    string
+}
+
+// Parsed tree of the last data file. Changing the fold level renders the
+// tree again, without parsing up to maxStructuredSize of text.
+var structuredCache structuredEntry
+
+// renderStructured renders data file as a tree or a table. Containers
+// nested deeper than fold levels are collapsed, zero means expand all.
+// Returns content, information for the preview header and depth of the tree.
+func renderStructured(path string, fold int) (string, string, int, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return "", "", 0, err
+	}
+	c := &structuredCache
+	if c.path != path || c.modTime != stat.ModTime().UnixNano() {
+		*c = structuredEntry{path: path, modTime: stat.ModTime().UnixNano(), format: structuredFormat(path), fold: -1}
+		if stat.Size() > maxStructuredSize {
+			c.err = errTooBig
+		} else {
+			c.roots, c.records, c.err = parseStructured(path, c.format)
+		}
+	}
+	if c.err != nil {
+		return "", "", 0, c.err
+	}
+
+	depth := 0
+	for _, root := range c.roots {
+		depth = max(depth, root.depth())
+	}
+	if c.fold != fold {
+		c.fold = fold
+		c.content, c.info = c.render()
+	}
+	return c.content, c.info, depth, nil
+}
+
+func parseStructured(path, format string) ([]*treeNode, [][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch format {
+	case "json":
+		root, err := parseJSON(data)
+		return []*treeNode{root}, nil, err
+	case "yaml":
+		roots, err := parseYAML(data)
+		return roots, nil, err
+	case "toml":
+		root, err := parseTOML(data)
+		return []*treeNode{root}, nil, err
+	case "csv", "tsv":
+		records, err := parseTable(data, format)
+		return nil, records, err
+	}
+	return nil, nil, fmt.Errorf("unknown format %q", format)
+}
+
+func (c *structuredEntry) render() (string, string) {
+	name := strings.ToUpper(c.format)
+	if c.records != nil {
+		columns := 0
+		for _, record := range c.records {
+			columns = max(columns, len(record))
+		}
+		info := fmt.Sprintf("%s %s × %s", name, plural(len(c.records)-1, "row"), plural(columns, "column"))
+		return renderTable(c.records, columns), info
+	}
+
+	var docs []string
+	for _, root := range c.roots {
+		var lines []string
+		if c.format == "json" {
+			lines = root.jsonLines(0, c.fold)
+		} else {
+			lines = root.yamlLines(0, c.fold)
+		}
+		docs = append(docs, strings.Join(lines, "\n"))
+	}
+	info := name
+	if len(docs) > 1 {
+		info += " " + plural(len(docs), "document")
+	}
+	if c.fold > 0 {
+		info += fmt.Sprintf(" folded at level %d", c.fold)
+	}
+	return strings.Join(docs, "\n---\n"), info
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, word)
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+func paint(style lipgloss.Style, s string) string {
+	if !withHighlight {
+		return s
+	}
+	return style.Render(s)
+}
+
+// folded returns collapsed container, like "{…} 3 keys".
+func (n *treeNode) folded() string {
+	if n.kind == nodeObject {
+		return "{…}" + paint(lineNumber, " "+plural(len(n.children), "key"))
+	}
+	return "[…]" + paint(lineNumber, " "+plural(len(n.children), "item"))
+}
+
+func (n *treeNode) isFolded(depth, fold int) bool {
+	return n.container() && len(n.children) > 0 && fold > 0 && depth >= fold
+}
+
+func (n *treeNode) scalar(quote bool) string {
+	switch n.kind {
+	case nodeString:
+		if quote || needsQuotes(n.value) {
+			return paint(treeString, strconv.Quote(n.value))
+		}
+		return paint(treeString, n.value)
+	case nodeNumber:
+		return paint(treeNumber, n.value)
+	case nodeBool, nodeNull, nodeAlias:
+		return paint(treeKeyword, n.value)
+	case nodeObject:
+		return "{}"
+	}
+	return "[]"
+}
+
+// needsQuotes reports whether YAML string would be read back as something
+// else without quotes.
+func needsQuotes(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s || strings.ContainsAny(s, "\n\t\"") {
+		return true
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.ContainsRune("-?:,[]{}#&*!|>'%@`", rune(s[0])) {
+		return true
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~":
+		return true
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// jsonLines pretty-prints node as JSON.
+func (n *treeNode) jsonLines(depth, fold int) []string {
+	if !n.container() || len(n.children) == 0 {
+		return []string{n.scalar(true)}
+	}
+	if n.isFolded(depth, fold) {
+		return []string{n.folded()}
+	}
+	open, end := "[", "]"
+	if n.kind == nodeObject {
+		open, end = "{", "}"
+	}
+	lines := []string{open}
+	for i, c := range n.children {
+		cl := c.jsonLines(depth+1, fold)
+		if n.kind == nodeObject {
+			cl[0] = paint(treeKey, strconv.Quote(c.key)) + ": " + cl[0]
+		}
+		if i < len(n.children)-1 {
+			cl[len(cl)-1] += ","
+		}
+		for _, l := range cl {
+			lines = append(lines, "  "+l)
+		}
+	}
+	return append(lines, end)
+}
+
+// yamlLines pretty-prints node in YAML block style.
+func (n *treeNode) yamlLines(depth, fold int) []string {
+	if !n.container() || len(n.children) == 0 {
+		return []string{n.scalar(false)}
+	}
+	if n.isFolded(depth, fold) {
+		return []string{n.folded()}
+	}
+	var lines []string
+	for _, c := range n.children {
+		cl := c.yamlLines(depth+1, fold)
+		if n.kind == nodeArray {
+			lines = append(lines, "- "+cl[0])
+			for _, l := range cl[1:] {
+				lines = append(lines, "  "+l)
+			}
+			continue
+		}
+		key := c.key
+		if needsQuotes(key) {
+			key = strconv.Quote(key)
+		}
+		key = paint(treeKey, key) + ":"
+		if len(cl) == 1 && !(c.container() && len(c.children) > 0 && !c.isFolded(depth+1, fold)) {
+			lines = append(lines, key+" "+cl[0])
+			continue
+		}
+		lines = append(lines, key)
+		for _, l := range cl {
+			lines = append(lines, "  "+l)
+		}
+	}
+	return lines
+}
+
+// parseJSON builds tree from JSON tokens, as unmarshalling into a map would
+// lose order of keys.
+func parseJSON(data []byte) (*treeNode, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	root, err := parseJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after top-level value")
+	}
+	return root, nil
+}
+
+func parseJSONValue(dec *json.Decoder) (*treeNode, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch v := t.(type) {
+	case json.Delim:
+		n := &treeNode{kind: nodeArray}
+		if v == '{' {
+			n.kind = nodeObject
+		}
+		for dec.More() {
+			var key string
+			if n.kind == nodeObject {
+				t, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				key, _ = t.(string)
+			}
+			c, err := parseJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			c.key = key
+			n.children = append(n.children, c)
+		}
+		if _, err := dec.Token(); err != nil { // Closing delimiter.
+			return nil, err
+		}
+		return n, nil
+	case string:
+		return &treeNode{kind: nodeString, value: v}, nil
+	case json.Number:
+		return &treeNode{kind: nodeNumber, value: v.String()}, nil
+	case bool:
+		return &treeNode{kind: nodeBool, value: strconv.FormatBool(v)}, nil
+	}
+	return &treeNode{kind: nodeNull, value: "null"}, nil
+}
+
+func parseYAML(data []byte) ([]*treeNode, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var roots []*treeNode
+	b := &yamlBuilder{expanding: make(map[*yaml.Node]bool)}
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(doc.Content) > 0 {
+			root, err := b.node(doc.Content[0])
+			if err != nil {
+				return nil, err
+			}
+			roots = append(roots, root)
+		}
+	}
+	if len(roots) == 0 {
+		return nil, errors.New("empty document")
+	}
+	return roots, nil
+}
+
+// yamlBuilder converts YAML nodes into a tree, expanding aliases.
+type yamlBuilder struct {
+	expanding map[*yaml.Node]bool // Anchored nodes being built.
+	nodes     int
+}
+
+var errTooManyNodes = errors.New("too many nodes after expanding aliases")
+
+func (b *yamlBuilder) node(y *yaml.Node) (*treeNode, error) {
+	b.nodes++
+	if b.nodes > maxYAMLNodes {
+		return nil, errTooManyNodes
+	}
+	if y.Kind == yaml.AliasNode && y.Alias != nil {
+		if b.expanding[y.Alias] {
+			// The alias refers to a node containing it.
+			return &treeNode{kind: nodeAlias, value: "*" + y.Value}, nil
+		}
+		y = y.Alias
+	}
+	if y.Anchor != "" {
+		b.expanding[y] = true
+		defer delete(b.expanding, y)
+	}
+	switch y.Kind {
+	case yaml.MappingNode:
+		n := &treeNode{kind: nodeObject}
+		for i := 0; i+1 < len(y.Content); i += 2 {
+			c, err := b.node(y.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			c.key = y.Content[i].Value
+			n.children = append(n.children, c)
+		}
+		return n, nil
+	case yaml.SequenceNode:
+		n := &treeNode{kind: nodeArray}
+		for _, item := range y.Content {
+			c, err := b.node(item)
+			if err != nil {
+				return nil, err
+			}
+			n.children = append(n.children, c)
+		}
+		return n, nil
+	}
+	switch y.ShortTag() {
+	case "!!int", "!!float":
+		return &treeNode{kind: nodeNumber, value: y.Value}, nil
+	case "!!bool":
+		return &treeNode{kind: nodeBool, value: y.Value}, nil
+	case "!!null":
+		return &treeNode{kind: nodeNull, value: "null"}, nil
+	}
+	return &treeNode{kind: nodeString, value: y.Value}, nil
+}
+
+func parseTOML(data []byte) (*treeNode, error) {
+	var doc map[string]any
+	meta, err := toml.Decode(string(data), &doc)
+	if err != nil {
+		return nil, err
+	}
+	// Maps lose order of keys, but metadata keeps it.
+	order := make(map[string]int)
+	for i, key := range meta.Keys() {
+		if _, ok := order[key.String()]; !ok {
+			order[key.String()] = i
+		}
+	}
+	return tomlNode(doc, "", order), nil
+}
+
+func tomlNode(v any, path string, order map[string]int) *treeNode {
+	switch v := v.(type) {
+	case map[string]any:
+		n := &treeNode{kind: nodeObject}
+		position := make(map[*treeNode]int)
+		for key, value := range v {
+			keyPath := toml.Key{key}.String()
+			if path != "" {
+				keyPath = path + "." + keyPath
+			}
+			c := tomlNode(value, keyPath, order)
+			c.key = key
+			n.children = append(n.children, c)
+			position[c] = len(order)
+			if i, ok := order[keyPath]; ok {
+				position[c] = i
+			}
+		}
+		sort.Slice(n.children, func(i, j int) bool {
+			a, b := n.children[i], n.children[j]
+			if position[a] != position[b] {
+				return position[a] < position[b]
+			}
+			return a.key < b.key
+		})
+		return n
+	case []map[string]any:
+		n := &treeNode{kind: nodeArray}
+		for _, item := range v {
+			n.children = append(n.children, tomlNode(item, path, order))
+		}
+		return n
+	case []any:
+		n := &treeNode{kind: nodeArray}
+		for _, item := range v {
+			n.children = append(n.children, tomlNode(item, path, order))
+		}
+		return n
+	case string:
+		return &treeNode{kind: nodeString, value: v}
+	case int64, float64:
+		return &treeNode{kind: nodeNumber, value: fmt.Sprint(v)}
+	case bool:
+		return &treeNode{kind: nodeBool, value: strconv.FormatBool(v)}
+	case time.Time:
+		return &treeNode{kind: nodeNumber, value: v.Format(time.RFC3339Nano)}
+	}
+	// Local dates and times.
+	return &treeNode{kind: nodeNumber, value: fmt.Sprint(v)}
+}
+
+func parseTable(data []byte, format string) ([][]string, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	if format == "tsv" {
+		r.Comma = '\t'
+	} else {
+		// Spreadsheets in many locales use semicolons.
+		firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+		if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+			r.Comma = ';'
+		}
+	}
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("empty table")
+	}
+	return records, nil
+}
+
+// renderTable aligns columns of records, the first record is the header.
+func renderTable(records [][]string, columns int) string {
+	cells := make([][]string, len(records))
+	widths := make([]int, columns)
+	for i, record := range records {
+		cells[i] = make([]string, columns)
+		for j, cell := range record {
+			cell = strings.NewReplacer("\r", "", "\n", "↵", "\t", " ").Replace(cell)
+			cell = ansi.Truncate(ansi.Strip(cell), maxColumnWidth, "…")
+			cells[i][j] = cell
+			widths[j] = max(widths[j], ansi.StringWidth(cell))
+		}
+	}
+
+	separator := paint(lineNumber, " │ ")
+	var b strings.Builder
+	for i, row := range cells {
+		for j, cell := range row {
+			if j > 0 {
+				b.WriteString(separator)
+			}
+			padding := ""
+			if j < columns-1 {
+				padding = strings.Repeat(" ", widths[j]-ansi.StringWidth(cell))
+			}
+			if i == 0 {
+				cell = paint(bold, cell)
+			}
+			b.WriteString(cell + padding)
+		}
+		b.WriteString("\n")
+		if i == 0 {
+			rule := make([]string, columns)
+			for j, w := range widths {
+				rule[j] = strings.Repeat("─", w)
+			}
+			b.WriteString(paint(lineNumber, strings.Join(rule, "─┼─")) + "\n")
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
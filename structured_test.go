@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseYAMLAliases(t *testing.T) {
+	roots, err := parseYAML([]byte("a: &a [*a]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := roots[0].children[0]
+	if a.kind != nodeArray || len(a.children) != 1 {
+		t.Fatalf("Failed: a is %v with %d children", a.kind, len(a.children))
+	}
+	if alias := a.children[0]; alias.kind != nodeAlias || alias.value != "*a" {
+		t.Errorf("Failed: recursive alias is %v %q", alias.kind, alias.value)
+	}
+
+	roots, err = parseYAML([]byte("x: &x {k: v}\ny: [*x, *x]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range roots[0].children[1].children {
+		if c.kind != nodeObject || c.children[0].value != "v" {
+			t.Errorf("Failed: alias is not expanded: %v", c.kind)
+		}
+	}
+
+	// Every level refers to the previous one ten times.
+	laughs := "l0: &l0 [lol]\n"
+	for i := 1; i < 10; i++ {
+		laughs += "l" + string(rune('0'+i)) + ": &l" + string(rune('0'+i)) + " [" +
+			strings.Repeat("*l"+string(rune('0'+i-1))+", ", 9) + "*l" + string(rune('0'+i-1)) + "]\n"
+	}
+	if _, err := parseYAML([]byte(laughs)); !errors.Is(err, errTooManyNodes) {
+		t.Errorf("Failed: billion laughs: %v", err)
+	}
+}
@@ -11,20 +11,34 @@ var (
 	bold         lipgloss.Style
 	warning      lipgloss.Style
 	cursor       lipgloss.Style
+	selected     lipgloss.Style
+	brokenLink   lipgloss.Style
+	diffAdded    lipgloss.Style
+	diffRemoved  lipgloss.Style
 	bar          lipgloss.Style
 	search       lipgloss.Style
 	danger       lipgloss.Style
+	lineNumber   lipgloss.Style
 	previewPlain lipgloss.Style
 	previewSplit lipgloss.Style
+	treeKey      lipgloss.Style
+	treeString   lipgloss.Style
+	treeNumber   lipgloss.Style
+	treeKeyword  lipgloss.Style
 )
 
 func initStyles() {
 	bold = lipgloss.NewStyle().Bold(true)
 	warning = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).PaddingLeft(1).PaddingRight(1)
 	cursor = lipgloss.NewStyle().Background(mainColor).Foreground(lipgloss.Color("#FFFFFF"))
+	selected = lipgloss.NewStyle().Foreground(mainColor).Bold(true)
+	brokenLink = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000"))
+	diffAdded = lipgloss.NewStyle().Foreground(searchColor)
+	diffRemoved = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000"))
 	bar = lipgloss.NewStyle().Background(barColor).Foreground(lipgloss.Color("#FFFFFF"))
 	search = lipgloss.NewStyle().Background(searchColor).Foreground(lipgloss.Color("#FFFFFF"))
 	danger = lipgloss.NewStyle().Background(lipgloss.Color("#FF0000")).Foreground(lipgloss.Color("#FFFFFF"))
+	lineNumber = lipgloss.NewStyle().Foreground(barColor)
 	previewPlain = lipgloss.NewStyle().PaddingLeft(2)
 	previewSplit = lipgloss.NewStyle().
 		MarginLeft(1).
@@ -32,4 +46,8 @@ func initStyles() {
 		BorderStyle(lipgloss.NormalBorder()).
 		BorderForeground(mainColor).
 		BorderLeft(true)
+	treeKey = lipgloss.NewStyle().Foreground(mainColor)
+	treeString = lipgloss.NewStyle().Foreground(searchColor)
+	treeNumber = lipgloss.NewStyle().Foreground(lipgloss.Color("#2AA1B3"))
+	treeKeyword = lipgloss.NewStyle().Foreground(lipgloss.Color("#D78700"))
 }
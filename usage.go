@@ -16,14 +16,30 @@ func usage(out io.Writer, full bool) {
 	}
 	put("    arrows, hjkl\tMove cursor")
 	put("    enter\tEnter directory")
+	put("    v\tView file")
 	put("    backspace\tExit directory")
 	put("    space\tToggle preview")
+	put("    tab\tFocus preview")
 	put("    esc, q\tExit with cd")
 	put("    ctrl+c\tExit without cd")
 	put("    /\tFuzzy search")
 	put("    d, delete\tDelete file or dir")
 	put("    y\tCopy to clipboard")
 	put("    .\tHide hidden files")
+	put("    r\tToggle rendered preview")
+	put("    S\tSort files")
+	put("    U\tDisk usage")
+	put("    D\tFind duplicates")
+	put("    m\tSelect file")
+	put("    c\tChecksums")
+	put("    C\tVerify checksums")
+	put("    p\tChange permissions")
+	put("    f\tFollow symlink")
+	put("    L\tCreate links")
+	put("    =\tCompare files")
+	put("    b\tFocus location bar")
+	put("    o\tGo to path")
+	put("    :\tCommand palette")
 	put("    ?\tShow help")
 	if full {
 		put("\n  Flags:\n")
@@ -32,7 +48,9 @@ func usage(out io.Writer, full bool) {
 		put("    --hide-hidden\thide hidden files")
 		put("    --preview\tdisplay preview")
 		put("    --with-border\tpreview with border")
+		put("    --mouse\tenable mouse")
 		put("    --fuzzy\tfuzzy mode")
+		put("    --list-styles\tlist highlighting styles")
 	}
 	_ = w.Flush()
 	_, _ = fmt.Fprintf(out, "\n")
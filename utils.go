@@ -1,7 +1,9 @@
 package main
 
 import (
+	"fmt"
 	"io/fs"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -12,12 +14,14 @@ func extension(path string) string {
 	return strings.TrimLeft(strings.ToLower(filepath.Ext(path)), ".")
 }
 
-func fileInfo(path string) os.FileInfo {
+// fileInfo returns info of the file, following symlinks. For broken
+// symlinks, info of the link itself is returned.
+func fileInfo(path string) (os.FileInfo, error) {
 	fi, err := os.Stat(path)
 	if err != nil {
-		panic(err)
+		return os.Lstat(path)
 	}
-	return fi
+	return fi, nil
 }
 
 func lookup(names []string, val string) string {
@@ -41,20 +45,23 @@ func remove(path string) {
 	}()
 }
 
-func leaveOnlyAscii(content []byte) string {
-	var result []byte
-
-	for _, b := range content {
-		if b == '\t' {
-			result = append(result, ' ', ' ', ' ', ' ')
-		} else if b == '\r' {
-			continue
-		} else if (b >= 32 && b <= 127) || b == '\n' { // '\n' is kept if newline needs to be retained
-			result = append(result, b)
-		}
+// humanSize formats size in bytes like "1.5MB".
+func humanSize(n int64) string {
+	size := float64(n)
+	if size <= 0 {
+		return "0B"
 	}
-
-	return string(result)
+	units := []string{"B", "KB", "MB", "GB", "TB", "PB", "EB"}
+	base := math.Log(size) / math.Log(1024)
+	unitIndex := int(math.Floor(base))
+	if unitIndex >= len(units) {
+		unitIndex = len(units) - 1
+	}
+	value := size / math.Pow(1024, float64(unitIndex))
+	if unitIndex == 0 {
+		return fmt.Sprintf("%.0f%s", value, units[unitIndex])
+	}
+	return fmt.Sprintf("%.1f%s", value, units[unitIndex])
 }
 
 func permBit(bit fs.FileMode, c byte) byte {